@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/torfstack/park/internal/auth"
@@ -10,9 +13,18 @@ import (
 	"github.com/torfstack/park/internal/db"
 	"github.com/torfstack/park/internal/logging"
 	"github.com/torfstack/park/internal/service"
+	"github.com/torfstack/park/internal/util"
 )
 
 func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		logging.Fatalf("ERROR: %s", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCmd returns the park command with all of its subcommands.
+func newRootCmd() *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "park",
 		Short: "Google Drive file synchronization tool",
@@ -22,6 +34,25 @@ func main() {
 	rootCmd.PersistentFlags().
 		BoolVarP(&debug, "debug", "d", false, "Enable debug output")
 
+	var configDir string
+	rootCmd.PersistentFlags().
+		StringVar(
+			&configDir, "config-dir", "",
+			"Directory for credentials, database and cache (default $"+util.HomeEnv+" or the XDG base directories)",
+		)
+	var profile string
+	rootCmd.PersistentFlags().
+		StringVarP(&profile, "profile", "p", util.DefaultProfile, "Profile with its own account, config and local directory")
+	var allDirs, dirs util.Dirs
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		config.SetInput(cmd.InOrStdin())
+		allDirs = util.ResolveDirs(configDir)
+		var err error
+		dirs, err = allDirs.ForProfile(profile)
+		return err
+	}
+
+	var allProfiles bool
 	daemonCmd := &cobra.Command{
 		Use:   "daemon",
 		Short: "Run in daemon mode (watch for changes and sync)",
@@ -29,56 +60,234 @@ func main() {
 			logging.SetDebug(debug)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			d, err := db.New(cmd.Context())
+			if !allProfiles {
+				return runDaemon(cmd.Context(), dirs)
+			}
+			profiles, err := allDirs.Profiles()
+			if err != nil {
+				return fmt.Errorf("main; error while listing profiles: %w", err)
+			}
+			// The profiles are isolated, so a profile that stops, e.g. because its sync was paused, does not stop others
+			errs := make([]error, len(profiles))
+			var wg sync.WaitGroup
+			for i, p := range profiles {
+				pd, err := allDirs.ForProfile(p)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				wg.Go(func() {
+					if err := runDaemon(cmd.Context(), pd); err != nil {
+						errs[i] = fmt.Errorf("profile %s: %w", p, err)
+					}
+				})
+			}
+			wg.Wait()
+			return errors.Join(errs...)
+		},
+	}
+	daemonCmd.Flags().
+		BoolVar(&allProfiles, "all-profiles", false, "Run the daemon for all initialized profiles concurrently")
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "InitialSync config",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			logging.SetDebug(debug)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.GetInteractive(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while running init cmd: %w", err)
+			}
+			drv, err := auth.DriveService(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while getting drive service: %w", err)
+			}
+			err = service.InitialSync(cmd.Context(), cfg, drv)
+			if err != nil {
+				return fmt.Errorf("main; error during initial sync: %w", err)
+			}
+			return nil
+		},
+	}
+
+	var allowDeletions bool
+	resumeCmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume sync after it was paused because too many files were removed locally",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			logging.SetDebug(debug)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Get(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while getting config: %w", err)
+			}
+			drv, err := auth.DriveService(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while getting drive service: %w", err)
+			}
+			err = service.Resume(cmd.Context(), cfg, drv, allowDeletions)
+			if err != nil {
+				return fmt.Errorf("main; error while running resume cmd: %w", err)
+			}
+			return nil
+		},
+	}
+	resumeCmd.Flags().
+		BoolVar(
+			&allowDeletions, "allow-deletions", false,
+			"Move the removed files to the Drive trash instead of restoring them",
+		)
+
+	var removeExcluded bool
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Edit config",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			logging.SetDebug(debug)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			previous, err := config.Get(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while getting config: %w", err)
+			}
+			cfg, err := config.Edit(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while running config cmd: %w", err)
+			}
+			if !cfg.SelectionChanged(previous) {
+				return nil
+			}
+
+			d, err := db.New(cmd.Context(), dirs.Data)
 			if err != nil {
 				return fmt.Errorf("could not create database: %w", err)
 			}
+			defer d.Close()
 			isInitialized, err := d.Queries().IsInitialized(cmd.Context())
 			if err != nil {
 				return fmt.Errorf("could not check if state is initialized: %w", err)
 			}
 			if !isInitialized {
-				return fmt.Errorf("run `park init` first")
+				return nil
 			}
-			cfg, err := config.Get(cmd.Context())
+			drv, err := auth.DriveService(cmd.Context(), dirs)
 			if err != nil {
-				return fmt.Errorf("main; error while getting config: %w", err)
+				return fmt.Errorf("main; error while getting drive service: %w", err)
 			}
-			err = service.RunDaemon(cmd.Context(), cfg)
+			err = service.ApplySelection(cmd.Context(), cfg, drv, removeExcluded)
 			if err != nil {
-				return fmt.Errorf("main; error while running daemon cmd: %w", err)
+				return fmt.Errorf("main; error while applying selected folders: %w", err)
 			}
 			return nil
 		},
 	}
+	configCmd.Flags().
+		BoolVar(
+			&removeExcluded, "remove-excluded", false,
+			"Remove local copies of folders that are no longer selected for sync",
+		)
 
-	initCmd := &cobra.Command{
-		Use:   "init",
-		Short: "InitialSync config",
+	var allConflicts bool
+	conflictsCmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "List unresolved sync conflicts",
 		PreRun: func(cmd *cobra.Command, args []string) {
 			logging.SetDebug(debug)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.GetInteractive(cmd.Context())
+			err := service.PrintConflicts(cmd.Context(), dirs, os.Stdout, allConflicts)
 			if err != nil {
-				return fmt.Errorf("main; error while running init cmd: %w", err)
+				return fmt.Errorf("main; error while running conflicts cmd: %w", err)
 			}
-			drv, err := auth.DriveService(cmd.Context())
+			return nil
+		},
+	}
+	conflictsCmd.Flags().
+		BoolVarP(&allConflicts, "all", "a", false, "List resolved conflicts as well")
+
+	var keep string
+	resolveCmd := &cobra.Command{
+		Use:   "resolve <path>",
+		Short: "Resolve a sync conflict by keeping the local version, the remote version or both",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			logging.SetDebug(debug)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolutions := map[string]config.ConflictPolicy{
+				"local":  config.ConflictPreferLocal,
+				"remote": config.ConflictPreferRemote,
+				"both":   config.ConflictKeepBoth,
+			}
+			resolution, ok := resolutions[keep]
+			if !ok {
+				return fmt.Errorf("--keep must be one of local, remote or both")
+			}
+			cfg, err := config.Get(cmd.Context(), dirs)
+			if err != nil {
+				return fmt.Errorf("main; error while getting config: %w", err)
+			}
+			drv, err := auth.DriveService(cmd.Context(), dirs)
 			if err != nil {
 				return fmt.Errorf("main; error while getting drive service: %w", err)
 			}
-			err = service.InitialSync(cmd.Context(), cfg, drv)
+			err = service.ResolveConflict(cmd.Context(), cfg, drv, args[0], resolution)
 			if err != nil {
-				return fmt.Errorf("main; error during initial sync: %w", err)
+				return fmt.Errorf("main; error while running resolve cmd: %w", err)
 			}
 			return nil
 		},
 	}
+	resolveCmd.Flags().
+		StringVar(&keep, "keep", "", "Version to keep: local, remote or both")
+	_ = resolveCmd.MarkFlagRequired("keep")
 
-	rootCmd.AddCommand(daemonCmd, initCmd)
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of sync, uploads in progress and failed downloads",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			logging.SetDebug(debug)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := service.PrintStatus(cmd.Context(), dirs, os.Stdout)
+			if err != nil {
+				return fmt.Errorf("main; error while running status cmd: %w", err)
+			}
+			return nil
+		},
+	}
 
-	if err := rootCmd.Execute(); err != nil {
-		logging.Fatalf("ERROR: %s", err)
-		os.Exit(1)
+	rootCmd.AddCommand(daemonCmd, initCmd, resumeCmd, configCmd, conflictsCmd, resolveCmd, statusCmd)
+	return rootCmd
+}
+
+func runDaemon(ctx context.Context, dirs util.Dirs) error {
+	d, err := db.New(ctx, dirs.Data)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+	isInitialized, err := d.Queries().IsInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check if state is initialized: %w", err)
+	}
+	if !isInitialized {
+		return fmt.Errorf("run `park init` first")
+	}
+	cfg, err := config.Get(ctx, dirs)
+	if err != nil {
+		return fmt.Errorf("main; error while getting config: %w", err)
+	}
+	drv, err := auth.DriveService(ctx, dirs)
+	if err != nil {
+		return fmt.Errorf("main; error while getting drive service: %w", err)
+	}
+	err = service.RunDaemon(ctx, cfg, drv)
+	if err != nil {
+		return fmt.Errorf("main; error while running daemon cmd: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/torfstack/park/internal/auth"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/util"
+)
+
+const testCredentials = `{"installed": {
+	"client_id": "client",
+	"client_secret": "secret",
+	"auth_uri": "https://accounts.google.com/o/oauth2/auth",
+	"token_uri": "https://oauth2.googleapis.com/token",
+	"redirect_uris": ["http://localhost"]
+}}`
+
+// testToken has no expiry, so it is never refreshed
+const testToken = `{"access_token": "token", "token_type": "Bearer"}`
+
+func TestInitAndDaemon(t *testing.T) {
+	fake := gdrive.NewFake()
+	folder := fake.AddFolder(fake.RootID(), "A")
+	fake.AddFile(folder, "a.txt", []byte("a"))
+	b := fake.AddFile(fake.RootID(), "b.txt", []byte("b"))
+	emulator := gdrive.NewEmulator(fake)
+	t.Cleanup(emulator.Close)
+	t.Setenv(auth.EndpointEnv, emulator.Endpoint())
+	configDir := seedConfigDir(t)
+	localDir := filepath.Join(t.TempDir(), "drive")
+
+	run := func(ctx context.Context, input string, args ...string) error {
+		cmd := newRootCmd()
+		cmd.SetArgs(append([]string{"--config-dir", configDir}, args...))
+		cmd.SetIn(strings.NewReader(input))
+		return cmd.ExecuteContext(ctx)
+	}
+
+	// All prompts but the one for the local directory are answered with their default
+	if err := run(context.Background(), localDir+"\n", "init"); err != nil {
+		t.Fatalf("park init = %v", err)
+	}
+	assertContent(t, localDir, "A/a.txt", "a")
+	assertContent(t, localDir, "b.txt", "b")
+
+	if err := fake.SetContent(b, []byte("remote")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(localDir, "c.txt"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- run(ctx, "", "daemon") }()
+
+	synced := func() bool {
+		local, _ := os.ReadFile(filepath.Join(localDir, "b.txt"))
+		c, uploaded := fake.Find(fake.RootID(), "c.txt")
+		remote, _ := fake.Content(c)
+		return string(local) == "remote" && uploaded && string(remote) == "local"
+	}
+	for deadline := time.Now().Add(10 * time.Second); !synced(); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("park daemon did not sync the remote and the local change")
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("park daemon = %v, want nil once cancelled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("park daemon did not stop once cancelled")
+	}
+}
+
+// seedConfigDir returns a config directory with OAuth client credentials and a database with a token, so park does
+// not open the browser to authenticate.
+func seedConfigDir(t *testing.T) string {
+	t.Helper()
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "credentials.json"), []byte(testCredentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := util.ResolveDirs(configDir).ForProfile(util.DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.New(context.Background(), dirs.Data)
+	if err != nil {
+		t.Fatalf("could not open database: %s", err)
+	}
+	defer d.Close()
+	if err = d.Queries().UpdateAuthToken(context.Background(), testToken); err != nil {
+		t.Fatalf("could not save token: %s", err)
+	}
+	return configDir
+}
+
+// assertContent fails t unless the file at relativePath below dir has the given content.
+func assertContent(t *testing.T, dir, relativePath, content string) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, relativePath))
+	if err != nil {
+		t.Fatalf("could not read %s: %s", relativePath, err)
+	}
+	if string(b) != content {
+		t.Errorf("content of %s = %q, want %q", relativePath, b, content)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/throttle"
 	"github.com/torfstack/park/internal/util"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -17,12 +19,18 @@ import (
 	"google.golang.org/api/option"
 )
 
-var (
-	credentialsFilePath = filepath.Join(util.ParkConfigDir, "credentials.json")
+const (
+	// EndpointEnv is the environment variable that overrides the URL of the Drive API, e.g. with the endpoint of a
+	// gdrive.Emulator in end-to-end tests
+	EndpointEnv = "PARK_DRIVE_ENDPOINT"
+
+	credentialsFile = "credentials.json"
 )
 
-func DriveService(ctx context.Context) (*drive.Service, error) {
-	b, err := os.ReadFile(credentialsFilePath)
+// DriveService returns the Remote backed by the Drive API, authenticated as the user with the OAuth client credentials
+// in the config directory and the token in the database in the data directory of dirs.
+func DriveService(ctx context.Context, dirs util.Dirs) (gdrive.Remote, error) {
+	b, err := os.ReadFile(filepath.Join(dirs.Config, credentialsFile))
 	if err != nil {
 		return nil, fmt.Errorf("could not read google credentials: %w", err)
 	}
@@ -32,20 +40,25 @@ func DriveService(ctx context.Context) (*drive.Service, error) {
 		return nil, fmt.Errorf("could not parse google config: %w", err)
 	}
 
-	client, err := getClient(ctx, config)
+	client, err := getClient(ctx, dirs.Data, config)
 	if err != nil {
 		return nil, fmt.Errorf("could not get client for drive service: %w", err)
 	}
 
-	drv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	client.Transport = throttle.NewTransport(client.Transport)
+	opts := []option.ClientOption{option.WithHTTPClient(client)}
+	if endpoint := os.Getenv(EndpointEnv); endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	drv, err := drive.NewService(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not create drive service: %w", err)
 	}
-	return drv, nil
+	return gdrive.NewService(drv, client), nil
 }
 
-func getClient(ctx context.Context, config *oauth2.Config) (*http.Client, error) {
-	d, err := db.New(ctx)
+func getClient(ctx context.Context, dataDir string, config *oauth2.Config) (*http.Client, error) {
+	d, err := db.New(ctx, dataDir)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to google drive: %w", err)
 	}
@@ -3,7 +3,9 @@ package config
 import (
 	"context"
 	"fmt"
+	"maps"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/torfstack/park/internal/db"
@@ -11,26 +13,146 @@ import (
 	"github.com/torfstack/park/internal/util"
 )
 
+// ConflictPolicy determines how a file that was changed both locally and remotely since the last sync is resolved.
+type ConflictPolicy string
+
+const (
+	// ConflictKeepBoth keeps the remote version at the original path and the local version as a conflict copy
+	ConflictKeepBoth ConflictPolicy = "keep-both"
+	// ConflictPreferLocal overwrites the remote version with the local one
+	ConflictPreferLocal ConflictPolicy = "prefer-local"
+	// ConflictPreferRemote overwrites the local version with the remote one
+	ConflictPreferRemote ConflictPolicy = "prefer-remote"
+	// ConflictManual keeps both versions untouched until the conflict is resolved with `park resolve`
+	ConflictManual ConflictPolicy = "manual"
+)
+
+// ShortcutMode determines how Drive shortcuts are materialized locally.
+type ShortcutMode string
+
+const (
+	// ShortcutFollow syncs the target of a shortcut at the path of the shortcut, duplicating its content locally
+	ShortcutFollow ShortcutMode = "follow"
+	// ShortcutSymlink creates a symlink at the path of the shortcut pointing to the synced target
+	ShortcutSymlink ShortcutMode = "symlink"
+	// ShortcutSkip does not sync shortcuts at all
+	ShortcutSkip ShortcutMode = "skip"
+)
+
 var (
-	defaultDriveDir     = filepath.Join(util.HomeDir(), "park-drive")
-	defaultSyncInterval = 60 * time.Second
+	defaultDriveDir           = filepath.Join(util.HomeDir(), "park-drive")
+	defaultSyncInterval       = 60 * time.Second
+	defaultMaxDeletions       = 100
+	defaultMaxDeletionPercent = 50
+	defaultConflictPolicy     = ConflictKeepBoth
+	defaultShortcutMode       = ShortcutFollow
+	defaultDownloadWorkers    = 4
+	defaultUploadWorkers      = 2
+	defaultExportFormats      = map[string]string{
+		"document":     "docx",
+		"spreadsheet":  "xlsx",
+		"presentation": "pdf",
+		"drawing":      "svg",
+	}
+
+	conflictPolicies = []ConflictPolicy{ConflictKeepBoth, ConflictPreferLocal, ConflictPreferRemote, ConflictManual}
+	shortcutModes    = []ShortcutMode{ShortcutFollow, ShortcutSymlink, ShortcutSkip}
 )
 
 type Config struct {
 	LocalDir     string        `toml:"local_dir"`
 	SyncInterval time.Duration `toml:"sync_interval"`
+	// MaxDeletions is the number of tracked files that may be trashed within ten minutes before sync is paused, 0
+	// disables the limit
+	MaxDeletions int `toml:"max_deletions"`
+	// MaxDeletionPercent is the percentage of tracked files that may be trashed within ten minutes before sync is
+	// paused, 0 disables the limit
+	MaxDeletionPercent int `toml:"max_deletion_percent"`
+	// ConflictPolicy determines how concurrent local and remote changes of a file are resolved
+	ConflictPolicy ConflictPolicy `toml:"conflict_policy"`
+	// ExportFormats maps kinds of Google-native documents, e.g. "document" or "spreadsheet", onto the file extension
+	// they are exported to or onto LinkFormat or DesktopLinkFormat. Documents of kinds without a mapping are not
+	// synced.
+	ExportFormats map[string]string `toml:"export_formats"`
+	// ShortcutMode determines how Drive shortcuts are materialized locally
+	ShortcutMode ShortcutMode `toml:"shortcut_mode"`
+	// SharedWithMe enables syncing files shared with the user to SharedWithMeDir
+	SharedWithMe bool `toml:"shared_with_me"`
+	// SharedWithMeInclude restricts the synced shared files to those owned by one of the given email addresses or
+	// with one of the given names, all shared files are synced if it is empty
+	SharedWithMeInclude []string `toml:"shared_with_me_include"`
+	// SyncInclude restricts sync to the given Drive folders, given by path or by ID prefixed with FolderIDPrefix.
+	// Everything is synced if it is empty.
+	SyncInclude []string `toml:"sync_include"`
+	// SyncExclude excludes the given Drive folders, given by path or by ID prefixed with FolderIDPrefix, from sync
+	SyncExclude []string `toml:"sync_exclude"`
+	// SyncPairs sync Drive folders to local directories of their own instead of syncing My Drive to LocalDir
+	SyncPairs []SyncPair `toml:"sync_pairs"`
+	// RootFolder is the Drive folder synced to LocalDir, given like SyncPair.Folder, it is only set in the configs
+	// returned by Pairs. My Drive is synced if it is empty.
+	RootFolder string `toml:"-"`
+	// DownloadWorkers and UploadWorkers are the numbers of files downloaded and uploaded concurrently
+	DownloadWorkers int `toml:"download_workers"`
+	UploadWorkers   int `toml:"upload_workers"`
+	// MaxDownloadRate and MaxUploadRate limit the bandwidth of all transfers in bytes per second, 0 disables the limit
+	MaxDownloadRate int64 `toml:"max_download_rate"`
+	MaxUploadRate   int64 `toml:"max_upload_rate"`
+	// WorkHours, e.g. "09:00-18:00", are the hours of weekdays during which WorkMaxDownloadRate and WorkMaxUploadRate
+	// apply instead of MaxDownloadRate and MaxUploadRate. There are no work hours if it is empty.
+	WorkHours           string `toml:"work_hours"`
+	WorkMaxDownloadRate int64  `toml:"work_max_download_rate"`
+	WorkMaxUploadRate   int64  `toml:"work_max_upload_rate"`
+	// Dirs are the directories park keeps its files in, the config is persisted in the database in Dirs.Data
+	Dirs util.Dirs `toml:"-"`
+}
+
+func Get(ctx context.Context, dirs util.Dirs) (Config, error) {
+	return get(ctx, dirs, false)
 }
 
-func Get(ctx context.Context) (Config, error) {
-	return get(ctx, false)
+func GetInteractive(ctx context.Context, dirs util.Dirs) (Config, error) {
+	return get(ctx, dirs, true)
 }
 
-func GetInteractive(ctx context.Context) (Config, error) {
-	return get(ctx, true)
+// Edit guides the user through changing an existing config and persists the result.
+func Edit(ctx context.Context, dirs util.Dirs) (Config, error) {
+	c, err := get(ctx, dirs, false)
+	if err != nil {
+		return c, err
+	}
+	err = guidedInitialization(&c)
+	if err != nil {
+		return c, fmt.Errorf("could not edit config interactively: %w", err)
+	}
+	return c, c.persist(ctx)
 }
 
-func get(ctx context.Context, interactive bool) (Config, error) {
-	d, err := db.New(ctx)
+// Stored returns the persisted config of the profile of dirs and whether there is one. Unlike Get, it does not
+// initialize the config if there is none.
+func Stored(ctx context.Context, dirs util.Dirs) (Config, bool, error) {
+	config, err := load(ctx, dirs)
+	if err != nil {
+		return Config{}, false, err
+	}
+	return config, !config.isNotInitialized(), nil
+}
+
+func get(ctx context.Context, dirs util.Dirs, interactive bool) (Config, error) {
+	config, err := load(ctx, dirs)
+	if err != nil {
+		return Config{}, err
+	}
+	if config.isNotInitialized() {
+		config, err = initConfig(ctx, dirs, interactive)
+		if err != nil {
+			return Config{}, fmt.Errorf("could not initialize config: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func load(ctx context.Context, dirs util.Dirs) (Config, error) {
+	d, err := db.New(ctx, dirs.Data)
 	if err != nil {
 		return Config{}, fmt.Errorf("could not create database: %w", err)
 	}
@@ -41,21 +163,42 @@ func get(ctx context.Context, interactive bool) (Config, error) {
 		return Config{}, fmt.Errorf("could not get config from database: %w", err)
 	}
 
-	config := Config{}
+	config := Config{Dirs: dirs}
 	config.LocalDir = c.RootDir
 	config.SyncInterval = time.Duration(c.SyncInterval) * time.Second
-	if config.isNotInitialized() {
-		config, err = initConfig(ctx, interactive)
-		if err != nil {
-			return Config{}, fmt.Errorf("could not initialize config: %w", err)
-		}
+	config.MaxDeletions = int(c.MaxDeletions)
+	config.MaxDeletionPercent = int(c.MaxDeletionPercent)
+	config.ConflictPolicy = ConflictPolicy(c.ConflictPolicy)
+	config.ShortcutMode = ShortcutMode(c.ShortcutMode)
+	config.SharedWithMe = c.SharedWithMe
+	config.SharedWithMeInclude = parseList(c.SharedWithMeInclude)
+	config.SyncInclude = parseFolders(c.SyncInclude)
+	config.SyncExclude = parseFolders(c.SyncExclude)
+	config.SyncPairs, err = parseSyncPairs(c.SyncPairs)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not parse sync pairs: %w", err)
+	}
+	config.DownloadWorkers = int(c.DownloadWorkers)
+	config.UploadWorkers = int(c.UploadWorkers)
+	config.MaxDownloadRate = c.MaxDownloadRate
+	config.MaxUploadRate = c.MaxUploadRate
+	config.WorkHours = c.WorkHours
+	config.WorkMaxDownloadRate = c.WorkMaxDownloadRate
+	config.WorkMaxUploadRate = c.WorkMaxUploadRate
+	config.ExportFormats, err = parseExportFormats(c.ExportFormats)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not parse export formats: %w", err)
 	}
-
 	return config, nil
 }
 
-func initConfig(ctx context.Context, interactive bool) (Config, error) {
+func initConfig(ctx context.Context, dirs util.Dirs, interactive bool) (Config, error) {
 	c := initialConfig()
+	c.Dirs = dirs
+	if dirs.Profile != "" && dirs.Profile != util.DefaultProfile {
+		// Profiles sharing a local directory would sync their files into each other's Drive
+		c.LocalDir += "-" + dirs.Profile
+	}
 	if interactive {
 		err := guidedInitialization(&c)
 		if err != nil {
@@ -66,15 +209,32 @@ func initConfig(ctx context.Context, interactive bool) (Config, error) {
 }
 
 func (c *Config) persist(ctx context.Context) error {
-	d, err := db.New(ctx)
+	d, err := db.New(ctx, c.Dirs.Data)
 	if err != nil {
 		return fmt.Errorf("could not create database: %w", err)
 	}
 	defer d.Close()
 
 	err = d.Queries().UpsertConfig(ctx, sqlc.UpsertConfigParams{
-		RootDir:      c.LocalDir,
-		SyncInterval: int64(c.SyncInterval.Seconds()),
+		RootDir:             c.LocalDir,
+		SyncInterval:        int64(c.SyncInterval.Seconds()),
+		MaxDeletions:        int64(c.MaxDeletions),
+		MaxDeletionPercent:  int64(c.MaxDeletionPercent),
+		ConflictPolicy:      string(c.ConflictPolicy),
+		ExportFormats:       formatExportFormats(c.ExportFormats),
+		ShortcutMode:        string(c.ShortcutMode),
+		SharedWithMe:        c.SharedWithMe,
+		SharedWithMeInclude: strings.Join(c.SharedWithMeInclude, ","),
+		SyncInclude:         strings.Join(c.SyncInclude, ","),
+		SyncExclude:         strings.Join(c.SyncExclude, ","),
+		SyncPairs:           formatSyncPairs(c.SyncPairs),
+		DownloadWorkers:     int64(c.DownloadWorkers),
+		UploadWorkers:       int64(c.UploadWorkers),
+		MaxDownloadRate:     c.MaxDownloadRate,
+		MaxUploadRate:       c.MaxUploadRate,
+		WorkHours:           c.WorkHours,
+		WorkMaxDownloadRate: c.WorkMaxDownloadRate,
+		WorkMaxUploadRate:   c.WorkMaxUploadRate,
 	})
 	if err != nil {
 		return fmt.Errorf("could not persist config: %w", err)
@@ -83,7 +243,17 @@ func (c *Config) persist(ctx context.Context) error {
 }
 
 func initialConfig() Config {
-	return Config{SyncInterval: defaultSyncInterval, LocalDir: defaultDriveDir}
+	return Config{
+		SyncInterval:       defaultSyncInterval,
+		LocalDir:           defaultDriveDir,
+		MaxDeletions:       defaultMaxDeletions,
+		MaxDeletionPercent: defaultMaxDeletionPercent,
+		ConflictPolicy:     defaultConflictPolicy,
+		ExportFormats:      maps.Clone(defaultExportFormats),
+		ShortcutMode:       defaultShortcutMode,
+		DownloadWorkers:    defaultDownloadWorkers,
+		UploadWorkers:      defaultUploadWorkers,
+	}
 }
 
 func (c *Config) isNotInitialized() bool {
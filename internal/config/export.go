@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// LinkFormat materializes Google-native documents as small JSON link files, e.g. .gdoc or .gsheet, like the
+	// official client does
+	LinkFormat = "link"
+	// DesktopLinkFormat materializes Google-native documents as freedesktop.org .desktop link files
+	DesktopLinkFormat = "desktop"
+
+	googleAppsMimeTypePrefix = "application/vnd.google-apps."
+)
+
+// exportMimeTypes maps the kind of Google-native document onto the file extensions it can be exported to and the
+// MIME type of the respective export. Every kind can be materialized as link file as well.
+var exportMimeTypes = map[string]map[string]string{
+	"document": {
+		"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"odt":  "application/vnd.oasis.opendocument.text",
+		"md":   "text/markdown",
+		"txt":  "text/plain",
+		"rtf":  "application/rtf",
+		"pdf":  "application/pdf",
+	},
+	"spreadsheet": {
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		"csv":  "text/csv",
+		"pdf":  "application/pdf",
+	},
+	"presentation": {
+		"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"odp":  "application/vnd.oasis.opendocument.presentation",
+		"pdf":  "application/pdf",
+	},
+	"drawing": {
+		"svg": "image/svg+xml",
+		"png": "image/png",
+		"pdf": "application/pdf",
+	},
+	"form": {},
+	"map":  {},
+	"site": {},
+	"jam":  {},
+}
+
+// ExportFormat returns the file extension and export MIME type configured for the Google-native document type
+// mimeType. For link files, the format is LinkFormat or DesktopLinkFormat and the export MIME type is empty. It
+// returns false if documents of that type are not synced.
+func (c *Config) ExportFormat(mimeType string) (string, string, bool) {
+	kind, ok := strings.CutPrefix(mimeType, googleAppsMimeTypePrefix)
+	if !ok {
+		return "", "", false
+	}
+	ext, ok := c.ExportFormats[kind]
+	if !ok {
+		return "", "", false
+	}
+	if ext == LinkFormat || ext == DesktopLinkFormat {
+		return ext, "", true
+	}
+	exportMimeType, ok := exportMimeTypes[kind][ext]
+	return ext, exportMimeType, ok
+}
+
+// parseExportFormats parses export formats of the form "document=docx,spreadsheet=csv".
+func parseExportFormats(s string) (map[string]string, error) {
+	formats := make(map[string]string)
+	for pair := range strings.SplitSeq(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kind, ext, ok := strings.Cut(pair, "=")
+		kind, ext = strings.TrimSpace(kind), strings.TrimSpace(ext)
+		if !ok {
+			return nil, fmt.Errorf("invalid export format '%s', expected <kind>=<extension>", pair)
+		}
+		exts, ok := exportMimeTypes[kind]
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown document kind '%s', expected one of %s",
+				kind, strings.Join(slices.Sorted(maps.Keys(exportMimeTypes)), ", "),
+			)
+		}
+		if _, ok = exts[ext]; !ok && ext != LinkFormat && ext != DesktopLinkFormat {
+			return nil, fmt.Errorf(
+				"cannot export %s to '%s', expected one of %s",
+				kind, ext, strings.Join(append(slices.Sorted(maps.Keys(exts)), LinkFormat, DesktopLinkFormat), ", "),
+			)
+		}
+		formats[kind] = ext
+	}
+	return formats, nil
+}
+
+func formatExportFormats(formats map[string]string) string {
+	pairs := make([]string, 0, len(formats))
+	for _, kind := range slices.Sorted(maps.Keys(formats)) {
+		pairs = append(pairs, kind+"="+formats[kind])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -2,19 +2,28 @@ package config
 
 import (
 	"bufio"
+	"cmp"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var (
-	inputFile = os.Stdin
+	// scanner reads the answers of the user to all prompts of the process, a scanner of its own would buffer input
+	// meant for later prompts and lose it
+	scanner = bufio.NewScanner(os.Stdin)
 )
 
-func guidedInitialization(config *Config) error {
-	scanner := bufio.NewScanner(inputFile)
+// SetInput makes all prompts read the answers of the user from r instead of stdin.
+func SetInput(r io.Reader) {
+	scanner = bufio.NewScanner(r)
+}
 
+func guidedInitialization(config *Config) error {
 	input, err := ask(scanner, fmt.Sprintf("Enter local directory path [default: %s]", config.LocalDir))
 	if err != nil {
 		return err
@@ -35,9 +44,245 @@ func guidedInitialization(config *Config) error {
 		config.SyncInterval = duration
 	}
 
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter max number of files trashed within ten minutes, 0 for no limit [default: %d]", config.MaxDeletions,
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.MaxDeletions, err = strconv.Atoi(input)
+		if err != nil || config.MaxDeletions < 0 {
+			return fmt.Errorf("invalid number format: %s", input)
+		}
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter max percentage of files trashed within ten minutes, 0 for no limit [default: %d]",
+			config.MaxDeletionPercent,
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.MaxDeletionPercent, err = strconv.Atoi(input)
+		if err != nil || config.MaxDeletionPercent < 0 || config.MaxDeletionPercent > 100 {
+			return fmt.Errorf("invalid percentage: %s", input)
+		}
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf("Enter conflict policy (%s) [default: %s]", joinPolicies(), config.ConflictPolicy),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		if !slices.Contains(conflictPolicies, ConflictPolicy(input)) {
+			return fmt.Errorf("invalid conflict policy: %s", input)
+		}
+		config.ConflictPolicy = ConflictPolicy(input)
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter export formats for Google documents, e.g. document=odt,spreadsheet=csv,form=link [default: %s]",
+			formatExportFormats(config.ExportFormats),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.ExportFormats, err = parseExportFormats(input)
+		if err != nil {
+			return fmt.Errorf("invalid export formats: %w", err)
+		}
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf("Enter shortcut mode (%s) [default: %s]", joinShortcutModes(), config.ShortcutMode),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		if !slices.Contains(shortcutModes, ShortcutMode(input)) {
+			return fmt.Errorf("invalid shortcut mode: %s", input)
+		}
+		config.ShortcutMode = ShortcutMode(input)
+	}
+
+	defaultSharedWithMe := "n"
+	if config.SharedWithMe {
+		defaultSharedWithMe = "y"
+	}
+	input, err = ask(scanner, fmt.Sprintf("Sync files shared with you (y/n) [default: %s]", defaultSharedWithMe))
+	if err != nil {
+		return err
+	}
+	switch input {
+	case "":
+	case "y", "yes":
+		config.SharedWithMe = true
+	case "n", "no":
+		config.SharedWithMe = false
+	default:
+		return fmt.Errorf("invalid answer: %s", input)
+	}
+
+	if config.SharedWithMe {
+		input, err = ask(
+			scanner,
+			fmt.Sprintf(
+				"Enter owner emails or names of shared files and folders to sync, empty for all [default: %s]",
+				strings.Join(config.SharedWithMeInclude, ","),
+			),
+		)
+		if err != nil {
+			return err
+		}
+		if input != "" {
+			config.SharedWithMeInclude = parseList(input)
+		}
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter Drive folders to sync, by path or as %s<folder ID>, '-' for all [default: %s]",
+			FolderIDPrefix, formatFolders(config.SyncInclude),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.SyncInclude = parseFolders(strings.TrimPrefix(input, "-"))
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter Drive folders to exclude from sync, by path or as %s<folder ID>, '-' for none [default: %s]",
+			FolderIDPrefix, formatFolders(config.SyncExclude),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.SyncExclude = parseFolders(strings.TrimPrefix(input, "-"))
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf(
+			"Enter Drive folders to sync to directories of their own instead of the whole Drive to the local directory, "+
+				"e.g. Projects/X=~/work/x,%s<folder ID>=/mnt/data/photos, '-' for none [default: %s]",
+			FolderIDPrefix, cmp.Or(formatSyncPairs(config.SyncPairs), "-"),
+		),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		config.SyncPairs, err = parseSyncPairs(strings.TrimPrefix(input, "-"))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, workers := range []struct {
+		prompt string
+		value  *int
+	}{
+		{"Enter number of concurrent downloads", &config.DownloadWorkers},
+		{"Enter number of concurrent uploads", &config.UploadWorkers},
+	} {
+		input, err = ask(scanner, fmt.Sprintf("%s [default: %d]", workers.prompt, *workers.value))
+		if err != nil {
+			return err
+		}
+		if input != "" {
+			*workers.value, err = strconv.Atoi(input)
+			if err != nil || *workers.value < 1 {
+				return fmt.Errorf("invalid number of workers: %s", input)
+			}
+		}
+	}
+
+	input, err = ask(
+		scanner,
+		fmt.Sprintf("Enter work hours of weekdays, e.g. 09:00-18:00, '-' for none [default: %s]", config.WorkHours),
+	)
+	if err != nil {
+		return err
+	}
+	if input != "" {
+		input = strings.TrimPrefix(input, "-")
+		if _, _, err = parseWorkHours(input); err != nil {
+			return fmt.Errorf("invalid work hours: %w", err)
+		}
+		config.WorkHours = input
+	}
+
+	type rateSetting struct {
+		prompt string
+		value  *int64
+	}
+	rates := []rateSetting{
+		{"Enter max download rate in bytes per second, e.g. 500K or 2M, 0 for no limit", &config.MaxDownloadRate},
+		{"Enter max upload rate in bytes per second, e.g. 500K or 2M, 0 for no limit", &config.MaxUploadRate},
+	}
+	if config.WorkHours != "" {
+		rates = append(
+			rates,
+			rateSetting{"Enter max download rate during work hours, 0 for no limit", &config.WorkMaxDownloadRate},
+			rateSetting{"Enter max upload rate during work hours, 0 for no limit", &config.WorkMaxUploadRate},
+		)
+	}
+	for _, rate := range rates {
+		input, err = ask(scanner, fmt.Sprintf("%s [default: %s]", rate.prompt, formatRate(*rate.value)))
+		if err != nil {
+			return err
+		}
+		if input != "" {
+			*rate.value, err = parseRate(input)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
+func joinPolicies() string {
+	policies := make([]string, len(conflictPolicies))
+	for i, p := range conflictPolicies {
+		policies[i] = string(p)
+	}
+	return strings.Join(policies, ", ")
+}
+
+func joinShortcutModes() string {
+	modes := make([]string, len(shortcutModes))
+	for i, m := range shortcutModes {
+		modes[i] = string(m)
+	}
+	return strings.Join(modes, ", ")
+}
+
 func ask(scanner *bufio.Scanner, prompt string) (string, error) {
 	fmt.Printf("%s: ", prompt)
 	if !scanner.Scan() {
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FolderIDPrefix marks entries of SyncInclude and SyncExclude that are Drive folder IDs instead of paths.
+const FolderIDPrefix = "id:"
+
+// parseFolders parses a comma-separated list of Drive folders given by path, relative to the local directory, or
+// by ID prefixed with FolderIDPrefix.
+func parseFolders(s string) []string {
+	folders := parseList(s)
+	for i, f := range folders {
+		if !strings.HasPrefix(f, FolderIDPrefix) {
+			folders[i] = filepath.Clean(strings.TrimPrefix(f, "/"))
+		}
+	}
+	return folders
+}
+
+func formatFolders(folders []string) string {
+	if len(folders) == 0 {
+		return "-"
+	}
+	return strings.Join(folders, ",")
+}
+
+// SelectionChanged reports whether the synced Drive folders or the sync pairs differ between c and other.
+func (c *Config) SelectionChanged(other Config) bool {
+	return !slices.Equal(c.SyncInclude, other.SyncInclude) || !slices.Equal(c.SyncExclude, other.SyncExclude) ||
+		!slices.Equal(c.SyncPairs, other.SyncPairs)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SharedDrive is a shared drive that is synced to a subdirectory of the local directory.
+type SharedDrive struct {
+	ID   string
+	Name string
+	// LocalDir is the directory the shared drive is synced to, relative to the local directory
+	LocalDir string
+}
+
+// ChooseSharedDrives asks the user which of the available shared drives to sync and to which subdirectory of the
+// local directory each of them is synced.
+func ChooseSharedDrives(available []SharedDrive) ([]SharedDrive, error) {
+	if len(available) == 0 {
+		return nil, nil
+	}
+
+	fmt.Println("Available shared drives:")
+	for i, d := range available {
+		fmt.Printf("  %d) %s\n", i+1, d.Name)
+	}
+	input, err := ask(scanner, "Enter the shared drives to sync, e.g. 1,3 [default: none]")
+	if err != nil {
+		return nil, err
+	}
+	if input == "" {
+		return nil, nil
+	}
+
+	var chosen []SharedDrive
+	localDirs := make(map[string]bool)
+	for s := range strings.SplitSeq(input, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 1 || n > len(available) {
+			return nil, fmt.Errorf("invalid shared drive: %s", s)
+		}
+		d := available[n-1]
+
+		input, err = ask(scanner, fmt.Sprintf("Enter subdirectory for %s [default: %s]", d.Name, d.Name))
+		if err != nil {
+			return nil, err
+		}
+		d.LocalDir = d.Name
+		if input != "" {
+			d.LocalDir = filepath.Clean(input)
+		}
+		if !filepath.IsLocal(d.LocalDir) {
+			return nil, fmt.Errorf("invalid subdirectory: %s", d.LocalDir)
+		}
+		if localDirs[d.LocalDir] {
+			return nil, fmt.Errorf("subdirectory %s is used more than once", d.LocalDir)
+		}
+		localDirs[d.LocalDir] = true
+		chosen = append(chosen, d)
+	}
+	return chosen, nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// SharedWithMeDir is the directory, relative to the local directory, files shared with the user are synced to.
+const SharedWithMeDir = "Shared with me"
+
+// IncludesSharedWithMe reports whether the file or folder with the given name and owners, which was shared with
+// the user, is synced.
+func (c *Config) IncludesSharedWithMe(name string, ownerEmails []string) bool {
+	if !c.SharedWithMe {
+		return false
+	}
+	if len(c.SharedWithMeInclude) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(
+		c.SharedWithMeInclude, func(include string) bool {
+			return include == name || slices.ContainsFunc(
+				ownerEmails, func(email string) bool { return strings.EqualFold(include, email) },
+			)
+		},
+	)
+}
+
+// parseList parses a comma-separated list, ignoring empty entries.
+func parseList(s string) []string {
+	var list []string
+	for e := range strings.SplitSeq(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/torfstack/park/internal/util"
+)
+
+// SyncPair is a Drive folder that is synced to a local directory of its own.
+type SyncPair struct {
+	// Folder is the Drive folder, given by path relative to My Drive or by ID prefixed with FolderIDPrefix
+	Folder string
+	// LocalDir is the absolute path of the directory the folder is synced to
+	LocalDir string
+}
+
+// syncPairSeparators separate the sync pairs of a list and the folder from the local directory of a sync pair, they
+// cannot be escaped.
+const syncPairSeparators = ",="
+
+// parseSyncPairs parses a comma-separated list of sync pairs of the form <folder>=<local directory>. A leading ~ of
+// a local directory is expanded to the home directory. The local directories must not contain each other, and
+// neither folders nor local directories may contain a comma or an equals sign.
+func parseSyncPairs(s string) ([]SyncPair, error) {
+	var pairs []SyncPair
+	for _, e := range parseList(s) {
+		folder, localDir, ok := strings.Cut(e, "=")
+		folder, localDir = strings.TrimSpace(folder), strings.TrimSpace(localDir)
+		if !ok || folder == "" || localDir == "" {
+			return nil, fmt.Errorf(
+				"invalid sync pair '%s', expected <Drive folder>=<local directory> without ',' in either", e,
+			)
+		}
+		if strings.Contains(localDir, "=") {
+			return nil, fmt.Errorf("invalid sync pair '%s', folders and local directories must not contain '='", e)
+		}
+		if !strings.HasPrefix(folder, FolderIDPrefix) {
+			folder = filepath.Clean(strings.TrimPrefix(folder, "/"))
+		}
+		if rest, ok := strings.CutPrefix(localDir, "~"); ok {
+			localDir = filepath.Join(util.HomeDir(), rest)
+		}
+		localDir, err := filepath.Abs(localDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local directory of sync pair '%s': %w", e, err)
+		}
+		if strings.ContainsAny(localDir, syncPairSeparators) {
+			// The home directory may contain them
+			return nil, fmt.Errorf("local directory '%s' of sync pair must not contain ',' or '='", localDir)
+		}
+
+		for _, p := range pairs {
+			if isWithin(localDir, p.LocalDir) || isWithin(p.LocalDir, localDir) {
+				return nil, fmt.Errorf("local directories '%s' and '%s' of sync pairs overlap", p.LocalDir, localDir)
+			}
+		}
+		pairs = append(pairs, SyncPair{Folder: folder, LocalDir: localDir})
+	}
+	return pairs, nil
+}
+
+func formatSyncPairs(pairs []SyncPair) string {
+	entries := make([]string, len(pairs))
+	for i, p := range pairs {
+		entries[i] = p.Folder + "=" + p.LocalDir
+	}
+	return strings.Join(entries, ",")
+}
+
+// isWithin reports whether path is dir or located below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// key identifies the state of the sync of p, which starts over if the folder or the local directory changes.
+func (p SyncPair) key() string {
+	h := sha256.Sum256([]byte(p.Folder + "\x00" + p.LocalDir))
+	return hex.EncodeToString(h[:6])
+}
+
+// Pairs returns a config for every sync pair of c. Each syncs the folder of its pair to the local directory of its
+// pair like c syncs My Drive to LocalDir, without shared drives and files shared with the user, and keeps the state
+// of its sync in a database of its own.
+func (c *Config) Pairs() []Config {
+	configs := make([]Config, len(c.SyncPairs))
+	for i, p := range c.SyncPairs {
+		pc := *c
+		pc.LocalDir = p.LocalDir
+		pc.RootFolder = p.Folder
+		pc.SyncPairs = nil
+		// The selection of folders refers to paths in My Drive
+		pc.SyncInclude, pc.SyncExclude = nil, nil
+		pc.SharedWithMe, pc.SharedWithMeInclude = false, nil
+		pc.Dirs = c.Dirs.ForSyncPair(p.key())
+		configs[i] = pc
+	}
+	return configs
+}
+
+// PairOf returns the config of the sync pair of c whose local directory contains the absolute path.
+func (c *Config) PairOf(path string) (Config, bool) {
+	pairs := c.Pairs()
+	i := slices.IndexFunc(pairs, func(p Config) bool { return isWithin(path, p.LocalDir) })
+	if i < 0 {
+		return Config{}, false
+	}
+	return pairs[i], true
+}
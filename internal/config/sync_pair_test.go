@@ -0,0 +1,54 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSyncPairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		home    string
+		want    []SyncPair
+		wantErr bool
+	}{
+		{name: "none", input: ""},
+		{
+			name:  "pairs",
+			input: " /Projects/X = /work/x , " + FolderIDPrefix + "abc=/mnt/photos",
+			want: []SyncPair{
+				{Folder: "Projects/X", LocalDir: "/work/x"},
+				{Folder: FolderIDPrefix + "abc", LocalDir: "/mnt/photos"},
+			},
+		},
+		{name: "home", input: "X=~/x", home: "/home/me", want: []SyncPair{{Folder: "X", LocalDir: "/home/me/x"}}},
+		{name: "missing local directory", input: "X=", wantErr: true},
+		{name: "missing separator", input: "X", wantErr: true},
+		{name: "overlapping local directories", input: "X=/work,Y=/work/y", wantErr: true},
+		{name: "comma in folder", input: "X,Y=/work/x", wantErr: true},
+		{name: "comma in local directory", input: "X=/work/x,y", wantErr: true},
+		{name: "equals sign in folder", input: "X=Y=/work/x", wantErr: true},
+		{name: "equals sign in local directory", input: "X=/work/x=y", wantErr: true},
+		{name: "comma in home directory", input: "X=~/x", home: "/home/a,b", wantErr: true},
+		{name: "equals sign in home directory", input: "X=~/x", home: "/home/a=b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.home != "" {
+				t.Setenv("HOME", tt.home)
+			}
+			got, err := parseSyncPairs(tt.input)
+			if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+				t.Errorf("parseSyncPairs(%q) = %v, %v, want %v, error %t", tt.input, got, err, tt.want, tt.wantErr)
+			}
+			if err == nil {
+				// Valid sync pairs survive being saved
+				again, err := parseSyncPairs(formatSyncPairs(got))
+				if err != nil || !slices.Equal(again, got) {
+					t.Errorf("parseSyncPairs(formatSyncPairs(%v)) = %v, %v", got, again, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rateUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// DownloadRateAt returns the maximum download rate in bytes per second at t, 0 if it is unlimited.
+func (c *Config) DownloadRateAt(t time.Time) int64 {
+	if c.inWorkHours(t) {
+		return c.WorkMaxDownloadRate
+	}
+	return c.MaxDownloadRate
+}
+
+// UploadRateAt returns the maximum upload rate in bytes per second at t, 0 if it is unlimited.
+func (c *Config) UploadRateAt(t time.Time) int64 {
+	if c.inWorkHours(t) {
+		return c.WorkMaxUploadRate
+	}
+	return c.MaxUploadRate
+}
+
+// inWorkHours reports whether t is a weekday within the configured work hours.
+func (c *Config) inWorkHours(t time.Time) bool {
+	start, end, err := parseWorkHours(c.WorkHours)
+	if err != nil || start == end || t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	now := sinceMidnight(t)
+	if start < end {
+		return now >= start && now < end
+	}
+	// Work hours spanning midnight, e.g. a night shift
+	return now >= start || now < end
+}
+
+// parseWorkHours parses work hours like "09:00-18:00" into their start and end as durations since midnight. Empty
+// work hours start and end at midnight.
+func parseWorkHours(s string) (time.Duration, time.Duration, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected <start>-<end>, e.g. 09:00-18:00")
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start '%s': %w", from, err)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end '%s': %w", to, err)
+	}
+	return sinceMidnight(start), sinceMidnight(end), nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// parseRate parses a rate in bytes per second, optionally with one of the binary suffixes K, M or G.
+func parseRate(s string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	multiplier := int64(1)
+	for _, u := range rateUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number = strings.TrimSuffix(number, u.suffix)
+			multiplier = u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+func formatRate(rate int64) string {
+	for _, u := range rateUnits {
+		if rate > 0 && rate%u.bytes == 0 {
+			return strconv.FormatInt(rate/u.bytes, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(rate, 10)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "1000", want: 1000},
+		{input: "500K", want: 500 << 10},
+		{input: "500k", want: 500 << 10},
+		{input: "2M", want: 2 << 20},
+		{input: "1G", want: 1 << 30},
+		{input: " 2M/s ", want: 2 << 20},
+		{input: "", wantErr: true},
+		{input: "-1K", wantErr: true},
+		{input: "1.5M", wantErr: true},
+		{input: "2T", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseRate(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseRate(%q) = %d, %v, want %d, error %t", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWorkHours(t *testing.T) {
+	tests := []struct {
+		input   string
+		start   time.Duration
+		end     time.Duration
+		wantErr bool
+	}{
+		{input: "", start: 0, end: 0},
+		{input: "09:00-18:00", start: 9 * time.Hour, end: 18 * time.Hour},
+		{input: "08:30 - 17:15", start: 8*time.Hour + 30*time.Minute, end: 17*time.Hour + 15*time.Minute},
+		{input: "22:00-06:00", start: 22 * time.Hour, end: 6 * time.Hour},
+		{input: "09:00", wantErr: true},
+		{input: "9-18", wantErr: true},
+		{input: "09:00-25:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			start, end, err := parseWorkHours(tt.input)
+			if (err != nil) != tt.wantErr || !tt.wantErr && (start != tt.start || end != tt.end) {
+				t.Errorf(
+					"parseWorkHours(%q) = %s, %s, %v, want %s, %s, error %t",
+					tt.input, start, end, err, tt.start, tt.end, tt.wantErr,
+				)
+			}
+		})
+	}
+}
+
+func TestDownloadRateAt(t *testing.T) {
+	// 2026-10-16 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name      string
+		workHours string
+		at        time.Time
+		want      int64
+	}{
+		{"no work hours", "", at(16, 12, 0), 2 << 20},
+		{"within work hours", "09:00-18:00", at(16, 12, 0), 500 << 10},
+		{"start of work hours", "09:00-18:00", at(16, 9, 0), 500 << 10},
+		{"end of work hours", "09:00-18:00", at(16, 18, 0), 2 << 20},
+		{"before work hours", "09:00-18:00", at(16, 8, 59), 2 << 20},
+		{"saturday", "09:00-18:00", at(17, 12, 0), 2 << 20},
+		{"sunday", "09:00-18:00", at(18, 12, 0), 2 << 20},
+		{"overnight before midnight", "22:00-06:00", at(16, 23, 0), 500 << 10},
+		{"overnight after midnight", "22:00-06:00", at(16, 5, 59), 500 << 10},
+		{"overnight during the day", "22:00-06:00", at(16, 12, 0), 2 << 20},
+		{"overnight on the weekend", "22:00-06:00", at(17, 23, 0), 2 << 20},
+		{"invalid work hours", "9-18", at(16, 12, 0), 2 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{WorkHours: tt.workHours, MaxDownloadRate: 2 << 20, WorkMaxDownloadRate: 500 << 10}
+			if got := c.DownloadRateAt(tt.at); got != tt.want {
+				t.Errorf("DownloadRateAt(%s) = %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/pressly/goose/v3"
 	"github.com/torfstack/park/internal/db/sqlc"
@@ -16,20 +18,24 @@ import (
 
 //go:generate sqlc generate -f sql/sqlc.yaml
 
-var (
-	dbName = "park.sqlite"
-)
-
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// migrations serializes running migrations, goose keeps its configuration in globals
+var migrations sync.Mutex
+
 type Database struct {
 	db *sql.DB
 }
 
-func New(ctx context.Context) (*Database, error) {
-	fp := filepath.Join(util.ParkConfigDir, dbName)
-	sqlDb, err := sql.Open("sqlite", fp)
+// New opens the database in dir, creating dir if necessary, and migrates it to the latest schema.
+func New(ctx context.Context, dir string) (*Database, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create database directory: %w", err)
+	}
+	fp := filepath.Join(dir, util.DatabaseFile)
+	// Concurrent transfers write to the database concurrently, which would fail immediately without a busy timeout
+	sqlDb, err := sql.Open("sqlite", fp+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("could not open database: %w", err)
 	}
@@ -42,6 +48,8 @@ func New(ctx context.Context) (*Database, error) {
 }
 
 func (d *Database) runMigrations(ctx context.Context) error {
+	migrations.Lock()
+	defer migrations.Unlock()
 	err := goose.SetDialect("sqlite")
 	if err != nil {
 		return fmt.Errorf("could not set dialect 'sqlite': %w", err)
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files
+    ADD COLUMN mime_type text NOT NULL DEFAULT '';
+
+ALTER TABLE files
+    ADD COLUMN head_revision text NOT NULL DEFAULT '';
+
+CREATE INDEX files_drive_id ON files (drive_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX files_drive_id;
+ALTER TABLE files DROP COLUMN head_revision;
+ALTER TABLE files DROP COLUMN mime_type;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE state
+    ADD COLUMN is_paused bool NOT NULL DEFAULT false;
+
+ALTER TABLE config
+    ADD COLUMN max_deletions int NOT NULL DEFAULT 100;
+
+ALTER TABLE config
+    ADD COLUMN max_deletion_percent int NOT NULL DEFAULT 50;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN max_deletion_percent;
+ALTER TABLE config DROP COLUMN max_deletions;
+ALTER TABLE state DROP COLUMN is_paused;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE config
+    ADD COLUMN conflict_policy text NOT NULL DEFAULT 'keep-both';
+
+CREATE TABLE conflicts
+(
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    path            text NOT NULL,
+    drive_id        text NOT NULL,
+    local_hash      blob NOT NULL,
+    local_size      int  NOT NULL,
+    local_modified  int  NOT NULL,
+    remote_revision text NOT NULL,
+    remote_md5      text NOT NULL,
+    remote_size     int  NOT NULL,
+    remote_modified int  NOT NULL,
+    conflict_copy   text NOT NULL DEFAULT '',
+    resolution      text NOT NULL DEFAULT '',
+    detected_at     int  NOT NULL,
+    resolved_at     int  NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE conflicts;
+ALTER TABLE config DROP COLUMN conflict_policy;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE files
+    ADD COLUMN remote_version int NOT NULL DEFAULT 0;
+
+ALTER TABLE files
+    ADD COLUMN export_format text NOT NULL DEFAULT '';
+
+ALTER TABLE config
+    ADD COLUMN export_formats text NOT NULL DEFAULT 'document=docx,spreadsheet=xlsx,presentation=pdf,drawing=svg';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN export_formats;
+ALTER TABLE files DROP COLUMN export_format;
+ALTER TABLE files DROP COLUMN remote_version;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE config
+    ADD COLUMN shortcut_mode text NOT NULL DEFAULT 'follow';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN shortcut_mode;
+-- +goose StatementEnd
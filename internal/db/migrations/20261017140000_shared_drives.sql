@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE shared_drives
+(
+    drive_id   text PRIMARY KEY,
+    name       text NOT NULL,
+    local_dir  text NOT NULL UNIQUE,
+    page_token text NOT NULL DEFAULT ''
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE shared_drives;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE config
+    ADD COLUMN shared_with_me bool NOT NULL DEFAULT false;
+
+ALTER TABLE config
+    ADD COLUMN shared_with_me_include text NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN shared_with_me_include;
+ALTER TABLE config DROP COLUMN shared_with_me;
+-- +goose StatementEnd
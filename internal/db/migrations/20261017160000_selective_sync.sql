@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE config
+    ADD COLUMN sync_include text NOT NULL DEFAULT '';
+
+ALTER TABLE config
+    ADD COLUMN sync_exclude text NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN sync_exclude;
+ALTER TABLE config DROP COLUMN sync_include;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE initial_sync_files
+(
+    drive_id       text PRIMARY KEY,
+    path           text NOT NULL,
+    head_revision  text NOT NULL,
+    remote_version int  NOT NULL,
+    content_hash   blob NOT NULL,
+    status         text NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE initial_sync_files;
+-- +goose StatementEnd
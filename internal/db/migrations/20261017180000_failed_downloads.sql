@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE failed_downloads
+(
+    drive_id     text PRIMARY KEY,
+    path         text NOT NULL,
+    error        text NOT NULL,
+    attempts     int  NOT NULL,
+    last_attempt int  NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE failed_downloads;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE config
+    ADD COLUMN download_workers int NOT NULL DEFAULT 4;
+
+ALTER TABLE config
+    ADD COLUMN upload_workers int NOT NULL DEFAULT 2;
+
+ALTER TABLE config
+    ADD COLUMN max_download_rate int NOT NULL DEFAULT 0;
+
+ALTER TABLE config
+    ADD COLUMN max_upload_rate int NOT NULL DEFAULT 0;
+
+ALTER TABLE config
+    ADD COLUMN work_hours text NOT NULL DEFAULT '';
+
+ALTER TABLE config
+    ADD COLUMN work_max_download_rate int NOT NULL DEFAULT 0;
+
+ALTER TABLE config
+    ADD COLUMN work_max_upload_rate int NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE config DROP COLUMN work_max_upload_rate;
+ALTER TABLE config DROP COLUMN work_max_download_rate;
+ALTER TABLE config DROP COLUMN work_hours;
+ALTER TABLE config DROP COLUMN max_upload_rate;
+ALTER TABLE config DROP COLUMN max_download_rate;
+ALTER TABLE config DROP COLUMN upload_workers;
+ALTER TABLE config DROP COLUMN download_workers;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE uploads
+(
+    path         text PRIMARY KEY,
+    drive_id     text NOT NULL,
+    session_uri  text NOT NULL,
+    size         int  NOT NULL,
+    content_hash blob NOT NULL,
+    uploaded     int  NOT NULL DEFAULT 0,
+    started_at   int  NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE uploads;
+-- +goose StatementEnd
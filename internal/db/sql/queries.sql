@@ -27,36 +27,245 @@ WHERE id = 1;
 UPDATE state
 SET is_initialized = true;
 
+-- name: IsPaused :one
+SELECT is_paused
+FROM state
+WHERE id = 1;
+
+-- name: SetPaused :exec
+UPDATE state
+SET is_paused = ?
+WHERE id = 1;
+
 
 -- name: GetConfig :one
-SELECT id, root_dir, sync_interval
+SELECT id, root_dir, sync_interval, max_deletions, max_deletion_percent, conflict_policy, export_formats,
+       shortcut_mode, shared_with_me, shared_with_me_include, sync_include, sync_exclude, download_workers,
+       upload_workers, max_download_rate, max_upload_rate, work_hours, work_max_download_rate, work_max_upload_rate,
+       sync_pairs
 FROM config
 WHERE id = 1;
 
 -- name: UpsertConfig :exec
-INSERT INTO config (root_dir, sync_interval)
-VALUES (?, ?)
-ON CONFLICT (id) DO UPDATE SET root_dir      = EXCLUDED.root_dir,
-                               sync_interval = EXCLUDED.sync_interval;
+INSERT INTO config (root_dir, sync_interval, max_deletions, max_deletion_percent, conflict_policy, export_formats,
+                    shortcut_mode, shared_with_me, shared_with_me_include, sync_include, sync_exclude,
+                    download_workers, upload_workers, max_download_rate, max_upload_rate, work_hours,
+                    work_max_download_rate, work_max_upload_rate, sync_pairs)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET root_dir               = EXCLUDED.root_dir,
+                               sync_interval          = EXCLUDED.sync_interval,
+                               max_deletions          = EXCLUDED.max_deletions,
+                               max_deletion_percent   = EXCLUDED.max_deletion_percent,
+                               conflict_policy        = EXCLUDED.conflict_policy,
+                               export_formats         = EXCLUDED.export_formats,
+                               shortcut_mode          = EXCLUDED.shortcut_mode,
+                               shared_with_me         = EXCLUDED.shared_with_me,
+                               shared_with_me_include = EXCLUDED.shared_with_me_include,
+                               sync_include           = EXCLUDED.sync_include,
+                               sync_exclude           = EXCLUDED.sync_exclude,
+                               download_workers       = EXCLUDED.download_workers,
+                               upload_workers         = EXCLUDED.upload_workers,
+                               max_download_rate      = EXCLUDED.max_download_rate,
+                               max_upload_rate        = EXCLUDED.max_upload_rate,
+                               work_hours             = EXCLUDED.work_hours,
+                               work_max_download_rate = EXCLUDED.work_max_download_rate,
+                               work_max_upload_rate   = EXCLUDED.work_max_upload_rate,
+                               sync_pairs             = EXCLUDED.sync_pairs;
 
 -- name: UpsertFile :exec
-INSERT INTO files (path, drive_id, content_hash, last_modified)
-VALUES (?, ?, ?, ?)
+INSERT INTO files (path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version,
+                   export_format, remote_md5)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT (path)
-    DO UPDATE SET content_hash  = EXCLUDED.content_hash,
-                  last_modified = EXCLUDED.last_modified;
+    DO UPDATE SET drive_id       = EXCLUDED.drive_id,
+                  content_hash   = EXCLUDED.content_hash,
+                  last_modified  = EXCLUDED.last_modified,
+                  mime_type      = EXCLUDED.mime_type,
+                  head_revision  = EXCLUDED.head_revision,
+                  remote_version = EXCLUDED.remote_version,
+                  export_format  = EXCLUDED.export_format,
+                  remote_md5     = EXCLUDED.remote_md5;
 
 -- name: GetFile :one
-SELECT path, drive_id, content_hash, last_modified
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
 FROM files
 WHERE path = ?;
 
+-- name: GetFilesByDriveID :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+WHERE drive_id = ?
+ORDER BY path;
+
+-- name: GetFilesByShortcutID :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+WHERE shortcut_id = ?
+ORDER BY path;
+
 -- name: GetAllFiles :many
-SELECT path, drive_id, content_hash, last_modified
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+ORDER BY path;
+
+-- name: GetFilesUnder :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
 FROM files
+WHERE path = sqlc.arg(path)
+   OR substr(path, 1, length(sqlc.arg(path)) + 1) = sqlc.arg(path) || '/'
 ORDER BY path;
 
+-- name: CountTrackedFiles :one
+SELECT count(*)
+FROM files
+WHERE mime_type != 'application/vnd.google-apps.folder';
+
+-- name: MoveFiles :exec
+UPDATE files
+SET path = sqlc.arg(new_path) || substr(path, length(sqlc.arg(old_path)) + 1)
+WHERE path = sqlc.arg(old_path)
+   OR substr(path, 1, length(sqlc.arg(old_path)) + 1) = sqlc.arg(old_path) || '/';
+
+-- name: SetShortcutID :exec
+UPDATE files
+SET shortcut_id = ?
+WHERE path = ?;
+
+-- name: UpdateShortcutIDUnder :exec
+UPDATE files
+SET shortcut_id = sqlc.arg(new_shortcut_id)
+WHERE shortcut_id = sqlc.arg(old_shortcut_id)
+  AND (path = sqlc.arg(path) OR substr(path, 1, length(sqlc.arg(path)) + 1) = sqlc.arg(path) || '/');
+
 -- name: DeleteFile :exec
 DELETE
 FROM files
 WHERE path = ?;
+
+-- name: DeleteFilesUnder :exec
+DELETE
+FROM files
+WHERE path = sqlc.arg(path)
+   OR substr(path, 1, length(sqlc.arg(path)) + 1) = sqlc.arg(path) || '/';
+
+-- name: InsertConflict :exec
+INSERT INTO conflicts (path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5,
+                       remote_size, remote_modified, conflict_copy, resolution, detected_at, resolved_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetConflicts :many
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+ORDER BY detected_at, id;
+
+-- name: GetUnresolvedConflicts :many
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+WHERE resolution = ''
+ORDER BY path;
+
+-- name: GetUnresolvedConflict :one
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+WHERE path = ?
+  AND resolution = ''
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ResolveConflict :exec
+UPDATE conflicts
+SET resolution    = ?,
+    conflict_copy = ?,
+    resolved_at   = ?
+WHERE id = ?;
+
+-- name: GetSharedDrives :many
+SELECT drive_id, name, local_dir, page_token
+FROM shared_drives
+ORDER BY local_dir;
+
+-- name: UpsertSharedDrive :exec
+INSERT INTO shared_drives (drive_id, name, local_dir, page_token)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (drive_id) DO UPDATE SET name       = EXCLUDED.name,
+                                     local_dir  = EXCLUDED.local_dir,
+                                     page_token = EXCLUDED.page_token;
+
+-- name: UpdateSharedDrivePageToken :exec
+UPDATE shared_drives
+SET page_token = ?
+WHERE drive_id = ?;
+
+-- name: GetInitialSyncFile :one
+SELECT drive_id, path, head_revision, remote_version, content_hash, status
+FROM initial_sync_files
+WHERE drive_id = ?;
+
+-- name: UpsertInitialSyncFile :exec
+INSERT INTO initial_sync_files (drive_id, path, head_revision, remote_version, content_hash, status)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (drive_id) DO UPDATE SET path           = EXCLUDED.path,
+                                     head_revision  = EXCLUDED.head_revision,
+                                     remote_version = EXCLUDED.remote_version,
+                                     content_hash   = EXCLUDED.content_hash,
+                                     status         = EXCLUDED.status;
+
+-- name: ClearInitialSync :exec
+DELETE
+FROM initial_sync_files;
+
+-- name: GetFailedDownloads :many
+SELECT drive_id, path, error, attempts, last_attempt
+FROM failed_downloads
+ORDER BY path;
+
+-- name: UpsertFailedDownload :exec
+INSERT INTO failed_downloads (drive_id, path, error, attempts, last_attempt)
+VALUES (?, ?, ?, 1, ?)
+ON CONFLICT (drive_id) DO UPDATE SET path         = EXCLUDED.path,
+                                     error        = EXCLUDED.error,
+                                     attempts     = failed_downloads.attempts + 1,
+                                     last_attempt = EXCLUDED.last_attempt;
+
+-- name: DeleteFailedDownload :exec
+DELETE
+FROM failed_downloads
+WHERE drive_id = ?;
+
+-- name: GetUpload :one
+SELECT path, drive_id, session_uri, size, content_hash, uploaded, started_at
+FROM uploads
+WHERE path = ?;
+
+-- name: GetUploads :many
+SELECT path, drive_id, session_uri, size, content_hash, uploaded, started_at
+FROM uploads
+ORDER BY path;
+
+-- name: UpsertUpload :exec
+INSERT INTO uploads (path, drive_id, session_uri, size, content_hash, uploaded, started_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (path) DO UPDATE SET drive_id     = EXCLUDED.drive_id,
+                                 session_uri  = EXCLUDED.session_uri,
+                                 size         = EXCLUDED.size,
+                                 content_hash = EXCLUDED.content_hash,
+                                 uploaded     = EXCLUDED.uploaded,
+                                 started_at   = EXCLUDED.started_at;
+
+-- name: UpdateUploadProgress :exec
+UPDATE uploads
+SET uploaded = ?
+WHERE path = ?;
+
+-- name: DeleteUpload :exec
+DELETE
+FROM uploads
+WHERE path = ?;
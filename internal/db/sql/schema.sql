@@ -3,14 +3,32 @@ CREATE TABLE state
     id             int PRIMARY KEY DEFAULT 1 CHECK (id = 1),
     page_token     text NOT NULL,
     auth_token     text NOT NULL,
-    is_initialized bool NOT NULL
+    is_initialized bool NOT NULL,
+    is_paused      bool NOT NULL DEFAULT false
 );
 
 CREATE TABLE config
 (
-    id            int PRIMARY KEY DEFAULT 1 CHECK (id = 1),
-    root_dir      text NOT NULL,
-    sync_interval int  NOT NULL
+    id                     int PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    root_dir               text NOT NULL,
+    sync_interval          int  NOT NULL,
+    max_deletions          int  NOT NULL DEFAULT 100,
+    max_deletion_percent   int  NOT NULL DEFAULT 50,
+    conflict_policy        text NOT NULL DEFAULT 'keep-both',
+    export_formats         text NOT NULL DEFAULT 'document=docx,spreadsheet=xlsx,presentation=pdf,drawing=svg',
+    shortcut_mode          text NOT NULL DEFAULT 'follow',
+    shared_with_me         bool NOT NULL DEFAULT false,
+    shared_with_me_include text NOT NULL DEFAULT '',
+    sync_include           text NOT NULL DEFAULT '',
+    sync_exclude           text NOT NULL DEFAULT '',
+    download_workers       int  NOT NULL DEFAULT 4,
+    upload_workers         int  NOT NULL DEFAULT 2,
+    max_download_rate      int  NOT NULL DEFAULT 0,
+    max_upload_rate        int  NOT NULL DEFAULT 0,
+    work_hours             text NOT NULL DEFAULT '',
+    work_max_download_rate int  NOT NULL DEFAULT 0,
+    work_max_upload_rate   int  NOT NULL DEFAULT 0,
+    sync_pairs             text NOT NULL DEFAULT ''
 );
 
 CREATE TABLE files
@@ -18,5 +36,68 @@ CREATE TABLE files
     path          text PRIMARY KEY,
     drive_id      text NOT NULL,
     content_hash  blob NOT NULL,
-    last_modified int  NOT NULL
+    last_modified int  NOT NULL,
+    mime_type     text NOT NULL DEFAULT '',
+    head_revision  text NOT NULL DEFAULT '',
+    remote_version int  NOT NULL DEFAULT 0,
+    export_format  text NOT NULL DEFAULT '',
+    remote_md5     text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX files_drive_id ON files (drive_id);
+
+CREATE TABLE conflicts
+(
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    path            text NOT NULL,
+    drive_id        text NOT NULL,
+    local_hash      blob NOT NULL,
+    local_size      int  NOT NULL,
+    local_modified  int  NOT NULL,
+    remote_revision text NOT NULL,
+    remote_md5      text NOT NULL,
+    remote_size     int  NOT NULL,
+    remote_modified int  NOT NULL,
+    conflict_copy   text NOT NULL DEFAULT '',
+    resolution      text NOT NULL DEFAULT '',
+    detected_at     int  NOT NULL,
+    resolved_at     int  NOT NULL DEFAULT 0
+);
+
+CREATE TABLE shared_drives
+(
+    drive_id   text PRIMARY KEY,
+    name       text NOT NULL,
+    local_dir  text NOT NULL UNIQUE,
+    page_token text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE initial_sync_files
+(
+    drive_id       text PRIMARY KEY,
+    path           text NOT NULL,
+    head_revision  text NOT NULL,
+    remote_version int  NOT NULL,
+    content_hash   blob NOT NULL,
+    status         text NOT NULL
+);
+
+CREATE TABLE failed_downloads
+(
+    drive_id     text PRIMARY KEY,
+    path         text NOT NULL,
+    error        text NOT NULL,
+    attempts     int  NOT NULL,
+    last_attempt int  NOT NULL
+);
+
+CREATE TABLE uploads
+(
+    path         text PRIMARY KEY,
+    drive_id     text NOT NULL,
+    session_uri  text NOT NULL,
+    size         int  NOT NULL,
+    content_hash blob NOT NULL,
+    uploaded     int  NOT NULL DEFAULT 0,
+    started_at   int  NOT NULL
 );
@@ -5,16 +5,80 @@
 package sqlc
 
 type Config struct {
-	ID           int64  `json:"id"`
-	RootDir      string `json:"root_dir"`
-	SyncInterval int64  `json:"sync_interval"`
+	ID                  int64  `json:"id"`
+	RootDir             string `json:"root_dir"`
+	SyncInterval        int64  `json:"sync_interval"`
+	MaxDeletions        int64  `json:"max_deletions"`
+	MaxDeletionPercent  int64  `json:"max_deletion_percent"`
+	ConflictPolicy      string `json:"conflict_policy"`
+	ExportFormats       string `json:"export_formats"`
+	ShortcutMode        string `json:"shortcut_mode"`
+	SharedWithMe        bool   `json:"shared_with_me"`
+	SharedWithMeInclude string `json:"shared_with_me_include"`
+	SyncInclude         string `json:"sync_include"`
+	SyncExclude         string `json:"sync_exclude"`
+	DownloadWorkers     int64  `json:"download_workers"`
+	UploadWorkers       int64  `json:"upload_workers"`
+	MaxDownloadRate     int64  `json:"max_download_rate"`
+	MaxUploadRate       int64  `json:"max_upload_rate"`
+	WorkHours           string `json:"work_hours"`
+	WorkMaxDownloadRate int64  `json:"work_max_download_rate"`
+	WorkMaxUploadRate   int64  `json:"work_max_upload_rate"`
+	SyncPairs           string `json:"sync_pairs"`
+}
+
+type Conflict struct {
+	ID             int64  `json:"id"`
+	Path           string `json:"path"`
+	DriveID        string `json:"drive_id"`
+	LocalHash      []byte `json:"local_hash"`
+	LocalSize      int64  `json:"local_size"`
+	LocalModified  int64  `json:"local_modified"`
+	RemoteRevision string `json:"remote_revision"`
+	RemoteMd5      string `json:"remote_md5"`
+	RemoteSize     int64  `json:"remote_size"`
+	RemoteModified int64  `json:"remote_modified"`
+	ConflictCopy   string `json:"conflict_copy"`
+	Resolution     string `json:"resolution"`
+	DetectedAt     int64  `json:"detected_at"`
+	ResolvedAt     int64  `json:"resolved_at"`
+}
+
+type FailedDownload struct {
+	DriveID     string `json:"drive_id"`
+	Path        string `json:"path"`
+	Error       string `json:"error"`
+	Attempts    int64  `json:"attempts"`
+	LastAttempt int64  `json:"last_attempt"`
 }
 
 type File struct {
-	Path         string `json:"path"`
-	DriveID      string `json:"drive_id"`
-	ContentHash  []byte `json:"content_hash"`
-	LastModified int64  `json:"last_modified"`
+	Path          string `json:"path"`
+	DriveID       string `json:"drive_id"`
+	ContentHash   []byte `json:"content_hash"`
+	LastModified  int64  `json:"last_modified"`
+	MimeType      string `json:"mime_type"`
+	HeadRevision  string `json:"head_revision"`
+	RemoteVersion int64  `json:"remote_version"`
+	ExportFormat  string `json:"export_format"`
+	RemoteMd5     string `json:"remote_md5"`
+	ShortcutID    string `json:"shortcut_id"`
+}
+
+type InitialSyncFile struct {
+	DriveID       string `json:"drive_id"`
+	Path          string `json:"path"`
+	HeadRevision  string `json:"head_revision"`
+	RemoteVersion int64  `json:"remote_version"`
+	ContentHash   []byte `json:"content_hash"`
+	Status        string `json:"status"`
+}
+
+type SharedDrive struct {
+	DriveID   string `json:"drive_id"`
+	Name      string `json:"name"`
+	LocalDir  string `json:"local_dir"`
+	PageToken string `json:"page_token"`
 }
 
 type State struct {
@@ -22,4 +86,15 @@ type State struct {
 	PageToken     string `json:"page_token"`
 	AuthToken     string `json:"auth_token"`
 	IsInitialized bool   `json:"is_initialized"`
+	IsPaused      bool   `json:"is_paused"`
+}
+
+type Upload struct {
+	Path        string `json:"path"`
+	DriveID     string `json:"drive_id"`
+	SessionUri  string `json:"session_uri"`
+	Size        int64  `json:"size"`
+	ContentHash []byte `json:"content_hash"`
+	Uploaded    int64  `json:"uploaded"`
+	StartedAt   int64  `json:"started_at"`
 }
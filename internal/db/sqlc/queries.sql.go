@@ -9,6 +9,40 @@ import (
 	"context"
 )
 
+const clearInitialSync = `-- name: ClearInitialSync :exec
+DELETE
+FROM initial_sync_files
+`
+
+func (q *Queries) ClearInitialSync(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, clearInitialSync)
+	return err
+}
+
+const countTrackedFiles = `-- name: CountTrackedFiles :one
+SELECT count(*)
+FROM files
+WHERE mime_type != 'application/vnd.google-apps.folder'
+`
+
+func (q *Queries) CountTrackedFiles(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countTrackedFiles)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteFailedDownload = `-- name: DeleteFailedDownload :exec
+DELETE
+FROM failed_downloads
+WHERE drive_id = ?
+`
+
+func (q *Queries) DeleteFailedDownload(ctx context.Context, driveID string) error {
+	_, err := q.db.ExecContext(ctx, deleteFailedDownload, driveID)
+	return err
+}
+
 const deleteFile = `-- name: DeleteFile :exec
 DELETE
 FROM files
@@ -20,8 +54,32 @@ func (q *Queries) DeleteFile(ctx context.Context, path string) error {
 	return err
 }
 
+const deleteFilesUnder = `-- name: DeleteFilesUnder :exec
+DELETE
+FROM files
+WHERE path = ?1
+   OR substr(path, 1, length(?1) + 1) = ?1 || '/'
+`
+
+func (q *Queries) DeleteFilesUnder(ctx context.Context, path string) error {
+	_, err := q.db.ExecContext(ctx, deleteFilesUnder, path)
+	return err
+}
+
+const deleteUpload = `-- name: DeleteUpload :exec
+DELETE
+FROM uploads
+WHERE path = ?
+`
+
+func (q *Queries) DeleteUpload(ctx context.Context, path string) error {
+	_, err := q.db.ExecContext(ctx, deleteUpload, path)
+	return err
+}
+
 const getAllFiles = `-- name: GetAllFiles :many
-SELECT path, drive_id, content_hash, last_modified
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
 FROM files
 ORDER BY path
 `
@@ -40,6 +98,12 @@ func (q *Queries) GetAllFiles(ctx context.Context) ([]File, error) {
 			&i.DriveID,
 			&i.ContentHash,
 			&i.LastModified,
+			&i.MimeType,
+			&i.HeadRevision,
+			&i.RemoteVersion,
+			&i.ExportFormat,
+			&i.RemoteMd5,
+			&i.ShortcutID,
 		); err != nil {
 			return nil, err
 		}
@@ -68,7 +132,10 @@ func (q *Queries) GetAuthToken(ctx context.Context) (string, error) {
 }
 
 const getConfig = `-- name: GetConfig :one
-SELECT id, root_dir, sync_interval
+SELECT id, root_dir, sync_interval, max_deletions, max_deletion_percent, conflict_policy, export_formats,
+       shortcut_mode, shared_with_me, shared_with_me_include, sync_include, sync_exclude, download_workers,
+       upload_workers, max_download_rate, max_upload_rate, work_hours, work_max_download_rate, work_max_upload_rate,
+       sync_pairs
 FROM config
 WHERE id = 1
 `
@@ -76,12 +143,114 @@ WHERE id = 1
 func (q *Queries) GetConfig(ctx context.Context) (Config, error) {
 	row := q.db.QueryRowContext(ctx, getConfig)
 	var i Config
-	err := row.Scan(&i.ID, &i.RootDir, &i.SyncInterval)
+	err := row.Scan(
+		&i.ID,
+		&i.RootDir,
+		&i.SyncInterval,
+		&i.MaxDeletions,
+		&i.MaxDeletionPercent,
+		&i.ConflictPolicy,
+		&i.ExportFormats,
+		&i.ShortcutMode,
+		&i.SharedWithMe,
+		&i.SharedWithMeInclude,
+		&i.SyncInclude,
+		&i.SyncExclude,
+		&i.DownloadWorkers,
+		&i.UploadWorkers,
+		&i.MaxDownloadRate,
+		&i.MaxUploadRate,
+		&i.WorkHours,
+		&i.WorkMaxDownloadRate,
+		&i.WorkMaxUploadRate,
+		&i.SyncPairs,
+	)
 	return i, err
 }
 
+const getConflicts = `-- name: GetConflicts :many
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+ORDER BY detected_at, id
+`
+
+func (q *Queries) GetConflicts(ctx context.Context) ([]Conflict, error) {
+	rows, err := q.db.QueryContext(ctx, getConflicts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Conflict
+	for rows.Next() {
+		var i Conflict
+		if err := rows.Scan(
+			&i.ID,
+			&i.Path,
+			&i.DriveID,
+			&i.LocalHash,
+			&i.LocalSize,
+			&i.LocalModified,
+			&i.RemoteRevision,
+			&i.RemoteMd5,
+			&i.RemoteSize,
+			&i.RemoteModified,
+			&i.ConflictCopy,
+			&i.Resolution,
+			&i.DetectedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFailedDownloads = `-- name: GetFailedDownloads :many
+SELECT drive_id, path, error, attempts, last_attempt
+FROM failed_downloads
+ORDER BY path
+`
+
+func (q *Queries) GetFailedDownloads(ctx context.Context) ([]FailedDownload, error) {
+	rows, err := q.db.QueryContext(ctx, getFailedDownloads)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FailedDownload
+	for rows.Next() {
+		var i FailedDownload
+		if err := rows.Scan(
+			&i.DriveID,
+			&i.Path,
+			&i.Error,
+			&i.Attempts,
+			&i.LastAttempt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFile = `-- name: GetFile :one
-SELECT path, drive_id, content_hash, last_modified
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
 FROM files
 WHERE path = ?
 `
@@ -94,6 +263,159 @@ func (q *Queries) GetFile(ctx context.Context, path string) (File, error) {
 		&i.DriveID,
 		&i.ContentHash,
 		&i.LastModified,
+		&i.MimeType,
+		&i.HeadRevision,
+		&i.RemoteVersion,
+		&i.ExportFormat,
+		&i.RemoteMd5,
+		&i.ShortcutID,
+	)
+	return i, err
+}
+
+const getFilesByDriveID = `-- name: GetFilesByDriveID :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+WHERE drive_id = ?
+ORDER BY path
+`
+
+func (q *Queries) GetFilesByDriveID(ctx context.Context, driveID string) ([]File, error) {
+	rows, err := q.db.QueryContext(ctx, getFilesByDriveID, driveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.Path,
+			&i.DriveID,
+			&i.ContentHash,
+			&i.LastModified,
+			&i.MimeType,
+			&i.HeadRevision,
+			&i.RemoteVersion,
+			&i.ExportFormat,
+			&i.RemoteMd5,
+			&i.ShortcutID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFilesByShortcutID = `-- name: GetFilesByShortcutID :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+WHERE shortcut_id = ?
+ORDER BY path
+`
+
+func (q *Queries) GetFilesByShortcutID(ctx context.Context, shortcutID string) ([]File, error) {
+	rows, err := q.db.QueryContext(ctx, getFilesByShortcutID, shortcutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.Path,
+			&i.DriveID,
+			&i.ContentHash,
+			&i.LastModified,
+			&i.MimeType,
+			&i.HeadRevision,
+			&i.RemoteVersion,
+			&i.ExportFormat,
+			&i.RemoteMd5,
+			&i.ShortcutID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFilesUnder = `-- name: GetFilesUnder :many
+SELECT path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version, export_format, remote_md5,
+       shortcut_id
+FROM files
+WHERE path = ?1
+   OR substr(path, 1, length(?1) + 1) = ?1 || '/'
+ORDER BY path
+`
+
+func (q *Queries) GetFilesUnder(ctx context.Context, path string) ([]File, error) {
+	rows, err := q.db.QueryContext(ctx, getFilesUnder, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.Path,
+			&i.DriveID,
+			&i.ContentHash,
+			&i.LastModified,
+			&i.MimeType,
+			&i.HeadRevision,
+			&i.RemoteVersion,
+			&i.ExportFormat,
+			&i.RemoteMd5,
+			&i.ShortcutID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getInitialSyncFile = `-- name: GetInitialSyncFile :one
+SELECT drive_id, path, head_revision, remote_version, content_hash, status
+FROM initial_sync_files
+WHERE drive_id = ?
+`
+
+func (q *Queries) GetInitialSyncFile(ctx context.Context, driveID string) (InitialSyncFile, error) {
+	row := q.db.QueryRowContext(ctx, getInitialSyncFile, driveID)
+	var i InitialSyncFile
+	err := row.Scan(
+		&i.DriveID,
+		&i.Path,
+		&i.HeadRevision,
+		&i.RemoteVersion,
+		&i.ContentHash,
+		&i.Status,
 	)
 	return i, err
 }
@@ -111,6 +433,217 @@ func (q *Queries) GetPageToken(ctx context.Context) (string, error) {
 	return page_token, err
 }
 
+const getSharedDrives = `-- name: GetSharedDrives :many
+SELECT drive_id, name, local_dir, page_token
+FROM shared_drives
+ORDER BY local_dir
+`
+
+func (q *Queries) GetSharedDrives(ctx context.Context) ([]SharedDrive, error) {
+	rows, err := q.db.QueryContext(ctx, getSharedDrives)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SharedDrive
+	for rows.Next() {
+		var i SharedDrive
+		if err := rows.Scan(
+			&i.DriveID,
+			&i.Name,
+			&i.LocalDir,
+			&i.PageToken,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnresolvedConflict = `-- name: GetUnresolvedConflict :one
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+WHERE path = ?
+  AND resolution = ''
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetUnresolvedConflict(ctx context.Context, path string) (Conflict, error) {
+	row := q.db.QueryRowContext(ctx, getUnresolvedConflict, path)
+	var i Conflict
+	err := row.Scan(
+		&i.ID,
+		&i.Path,
+		&i.DriveID,
+		&i.LocalHash,
+		&i.LocalSize,
+		&i.LocalModified,
+		&i.RemoteRevision,
+		&i.RemoteMd5,
+		&i.RemoteSize,
+		&i.RemoteModified,
+		&i.ConflictCopy,
+		&i.Resolution,
+		&i.DetectedAt,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getUnresolvedConflicts = `-- name: GetUnresolvedConflicts :many
+SELECT id, path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5, remote_size,
+       remote_modified, conflict_copy, resolution, detected_at, resolved_at
+FROM conflicts
+WHERE resolution = ''
+ORDER BY path
+`
+
+func (q *Queries) GetUnresolvedConflicts(ctx context.Context) ([]Conflict, error) {
+	rows, err := q.db.QueryContext(ctx, getUnresolvedConflicts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Conflict
+	for rows.Next() {
+		var i Conflict
+		if err := rows.Scan(
+			&i.ID,
+			&i.Path,
+			&i.DriveID,
+			&i.LocalHash,
+			&i.LocalSize,
+			&i.LocalModified,
+			&i.RemoteRevision,
+			&i.RemoteMd5,
+			&i.RemoteSize,
+			&i.RemoteModified,
+			&i.ConflictCopy,
+			&i.Resolution,
+			&i.DetectedAt,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUpload = `-- name: GetUpload :one
+SELECT path, drive_id, session_uri, size, content_hash, uploaded, started_at
+FROM uploads
+WHERE path = ?
+`
+
+func (q *Queries) GetUpload(ctx context.Context, path string) (Upload, error) {
+	row := q.db.QueryRowContext(ctx, getUpload, path)
+	var i Upload
+	err := row.Scan(
+		&i.Path,
+		&i.DriveID,
+		&i.SessionUri,
+		&i.Size,
+		&i.ContentHash,
+		&i.Uploaded,
+		&i.StartedAt,
+	)
+	return i, err
+}
+
+const getUploads = `-- name: GetUploads :many
+SELECT path, drive_id, session_uri, size, content_hash, uploaded, started_at
+FROM uploads
+ORDER BY path
+`
+
+func (q *Queries) GetUploads(ctx context.Context) ([]Upload, error) {
+	rows, err := q.db.QueryContext(ctx, getUploads)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.Path,
+			&i.DriveID,
+			&i.SessionUri,
+			&i.Size,
+			&i.ContentHash,
+			&i.Uploaded,
+			&i.StartedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertConflict = `-- name: InsertConflict :exec
+INSERT INTO conflicts (path, drive_id, local_hash, local_size, local_modified, remote_revision, remote_md5,
+                       remote_size, remote_modified, conflict_copy, resolution, detected_at, resolved_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertConflictParams struct {
+	Path           string `json:"path"`
+	DriveID        string `json:"drive_id"`
+	LocalHash      []byte `json:"local_hash"`
+	LocalSize      int64  `json:"local_size"`
+	LocalModified  int64  `json:"local_modified"`
+	RemoteRevision string `json:"remote_revision"`
+	RemoteMd5      string `json:"remote_md5"`
+	RemoteSize     int64  `json:"remote_size"`
+	RemoteModified int64  `json:"remote_modified"`
+	ConflictCopy   string `json:"conflict_copy"`
+	Resolution     string `json:"resolution"`
+	DetectedAt     int64  `json:"detected_at"`
+	ResolvedAt     int64  `json:"resolved_at"`
+}
+
+func (q *Queries) InsertConflict(ctx context.Context, arg InsertConflictParams) error {
+	_, err := q.db.ExecContext(ctx, insertConflict,
+		arg.Path,
+		arg.DriveID,
+		arg.LocalHash,
+		arg.LocalSize,
+		arg.LocalModified,
+		arg.RemoteRevision,
+		arg.RemoteMd5,
+		arg.RemoteSize,
+		arg.RemoteModified,
+		arg.ConflictCopy,
+		arg.Resolution,
+		arg.DetectedAt,
+		arg.ResolvedAt,
+	)
+	return err
+}
+
 const isInitialized = `-- name: IsInitialized :one
 SELECT is_initialized
 FROM state
@@ -124,6 +657,61 @@ func (q *Queries) IsInitialized(ctx context.Context) (bool, error) {
 	return is_initialized, err
 }
 
+const isPaused = `-- name: IsPaused :one
+SELECT is_paused
+FROM state
+WHERE id = 1
+`
+
+func (q *Queries) IsPaused(ctx context.Context) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isPaused)
+	var is_paused bool
+	err := row.Scan(&is_paused)
+	return is_paused, err
+}
+
+const moveFiles = `-- name: MoveFiles :exec
+UPDATE files
+SET path = ?1 || substr(path, length(?2) + 1)
+WHERE path = ?2
+   OR substr(path, 1, length(?2) + 1) = ?2 || '/'
+`
+
+type MoveFilesParams struct {
+	NewPath string `json:"new_path"`
+	OldPath string `json:"old_path"`
+}
+
+func (q *Queries) MoveFiles(ctx context.Context, arg MoveFilesParams) error {
+	_, err := q.db.ExecContext(ctx, moveFiles, arg.NewPath, arg.OldPath)
+	return err
+}
+
+const resolveConflict = `-- name: ResolveConflict :exec
+UPDATE conflicts
+SET resolution    = ?,
+    conflict_copy = ?,
+    resolved_at   = ?
+WHERE id = ?
+`
+
+type ResolveConflictParams struct {
+	Resolution   string `json:"resolution"`
+	ConflictCopy string `json:"conflict_copy"`
+	ResolvedAt   int64  `json:"resolved_at"`
+	ID           int64  `json:"id"`
+}
+
+func (q *Queries) ResolveConflict(ctx context.Context, arg ResolveConflictParams) error {
+	_, err := q.db.ExecContext(ctx, resolveConflict,
+		arg.Resolution,
+		arg.ConflictCopy,
+		arg.ResolvedAt,
+		arg.ID,
+	)
+	return err
+}
+
 const setInitialized = `-- name: SetInitialized :exec
 UPDATE state
 SET is_initialized = true
@@ -134,6 +722,33 @@ func (q *Queries) SetInitialized(ctx context.Context) error {
 	return err
 }
 
+const setPaused = `-- name: SetPaused :exec
+UPDATE state
+SET is_paused = ?
+WHERE id = 1
+`
+
+func (q *Queries) SetPaused(ctx context.Context, isPaused bool) error {
+	_, err := q.db.ExecContext(ctx, setPaused, isPaused)
+	return err
+}
+
+const setShortcutID = `-- name: SetShortcutID :exec
+UPDATE files
+SET shortcut_id = ?
+WHERE path = ?
+`
+
+type SetShortcutIDParams struct {
+	ShortcutID string `json:"shortcut_id"`
+	Path       string `json:"path"`
+}
+
+func (q *Queries) SetShortcutID(ctx context.Context, arg SetShortcutIDParams) error {
+	_, err := q.db.ExecContext(ctx, setShortcutID, arg.ShortcutID, arg.Path)
+	return err
+}
+
 const updateAuthToken = `-- name: UpdateAuthToken :exec
 UPDATE state
 SET auth_token = ?
@@ -156,36 +771,181 @@ func (q *Queries) UpdatePageToken(ctx context.Context, pageToken string) error {
 	return err
 }
 
+const updateSharedDrivePageToken = `-- name: UpdateSharedDrivePageToken :exec
+UPDATE shared_drives
+SET page_token = ?
+WHERE drive_id = ?
+`
+
+type UpdateSharedDrivePageTokenParams struct {
+	PageToken string `json:"page_token"`
+	DriveID   string `json:"drive_id"`
+}
+
+func (q *Queries) UpdateSharedDrivePageToken(ctx context.Context, arg UpdateSharedDrivePageTokenParams) error {
+	_, err := q.db.ExecContext(ctx, updateSharedDrivePageToken, arg.PageToken, arg.DriveID)
+	return err
+}
+
+const updateShortcutIDUnder = `-- name: UpdateShortcutIDUnder :exec
+UPDATE files
+SET shortcut_id = ?1
+WHERE shortcut_id = ?2
+  AND (path = ?3 OR substr(path, 1, length(?3) + 1) = ?3 || '/')
+`
+
+type UpdateShortcutIDUnderParams struct {
+	NewShortcutID string `json:"new_shortcut_id"`
+	OldShortcutID string `json:"old_shortcut_id"`
+	Path          string `json:"path"`
+}
+
+func (q *Queries) UpdateShortcutIDUnder(ctx context.Context, arg UpdateShortcutIDUnderParams) error {
+	_, err := q.db.ExecContext(ctx, updateShortcutIDUnder, arg.NewShortcutID, arg.OldShortcutID, arg.Path)
+	return err
+}
+
+const updateUploadProgress = `-- name: UpdateUploadProgress :exec
+UPDATE uploads
+SET uploaded = ?
+WHERE path = ?
+`
+
+type UpdateUploadProgressParams struct {
+	Uploaded int64  `json:"uploaded"`
+	Path     string `json:"path"`
+}
+
+func (q *Queries) UpdateUploadProgress(ctx context.Context, arg UpdateUploadProgressParams) error {
+	_, err := q.db.ExecContext(ctx, updateUploadProgress, arg.Uploaded, arg.Path)
+	return err
+}
+
 const upsertConfig = `-- name: UpsertConfig :exec
-INSERT INTO config (root_dir, sync_interval)
-VALUES (?, ?)
-ON CONFLICT (id) DO UPDATE SET root_dir      = EXCLUDED.root_dir,
-                               sync_interval = EXCLUDED.sync_interval
+INSERT INTO config (root_dir, sync_interval, max_deletions, max_deletion_percent, conflict_policy, export_formats,
+                    shortcut_mode, shared_with_me, shared_with_me_include, sync_include, sync_exclude,
+                    download_workers, upload_workers, max_download_rate, max_upload_rate, work_hours,
+                    work_max_download_rate, work_max_upload_rate, sync_pairs)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET root_dir               = EXCLUDED.root_dir,
+                               sync_interval          = EXCLUDED.sync_interval,
+                               max_deletions          = EXCLUDED.max_deletions,
+                               max_deletion_percent   = EXCLUDED.max_deletion_percent,
+                               conflict_policy        = EXCLUDED.conflict_policy,
+                               export_formats         = EXCLUDED.export_formats,
+                               shortcut_mode          = EXCLUDED.shortcut_mode,
+                               shared_with_me         = EXCLUDED.shared_with_me,
+                               shared_with_me_include = EXCLUDED.shared_with_me_include,
+                               sync_include           = EXCLUDED.sync_include,
+                               sync_exclude           = EXCLUDED.sync_exclude,
+                               download_workers       = EXCLUDED.download_workers,
+                               upload_workers         = EXCLUDED.upload_workers,
+                               max_download_rate      = EXCLUDED.max_download_rate,
+                               max_upload_rate        = EXCLUDED.max_upload_rate,
+                               work_hours             = EXCLUDED.work_hours,
+                               work_max_download_rate = EXCLUDED.work_max_download_rate,
+                               work_max_upload_rate   = EXCLUDED.work_max_upload_rate,
+                               sync_pairs             = EXCLUDED.sync_pairs
 `
 
 type UpsertConfigParams struct {
-	RootDir      string `json:"root_dir"`
-	SyncInterval int64  `json:"sync_interval"`
+	RootDir             string `json:"root_dir"`
+	SyncInterval        int64  `json:"sync_interval"`
+	MaxDeletions        int64  `json:"max_deletions"`
+	MaxDeletionPercent  int64  `json:"max_deletion_percent"`
+	ConflictPolicy      string `json:"conflict_policy"`
+	ExportFormats       string `json:"export_formats"`
+	ShortcutMode        string `json:"shortcut_mode"`
+	SharedWithMe        bool   `json:"shared_with_me"`
+	SharedWithMeInclude string `json:"shared_with_me_include"`
+	SyncInclude         string `json:"sync_include"`
+	SyncExclude         string `json:"sync_exclude"`
+	DownloadWorkers     int64  `json:"download_workers"`
+	UploadWorkers       int64  `json:"upload_workers"`
+	MaxDownloadRate     int64  `json:"max_download_rate"`
+	MaxUploadRate       int64  `json:"max_upload_rate"`
+	WorkHours           string `json:"work_hours"`
+	WorkMaxDownloadRate int64  `json:"work_max_download_rate"`
+	WorkMaxUploadRate   int64  `json:"work_max_upload_rate"`
+	SyncPairs           string `json:"sync_pairs"`
 }
 
 func (q *Queries) UpsertConfig(ctx context.Context, arg UpsertConfigParams) error {
-	_, err := q.db.ExecContext(ctx, upsertConfig, arg.RootDir, arg.SyncInterval)
+	_, err := q.db.ExecContext(ctx, upsertConfig,
+		arg.RootDir,
+		arg.SyncInterval,
+		arg.MaxDeletions,
+		arg.MaxDeletionPercent,
+		arg.ConflictPolicy,
+		arg.ExportFormats,
+		arg.ShortcutMode,
+		arg.SharedWithMe,
+		arg.SharedWithMeInclude,
+		arg.SyncInclude,
+		arg.SyncExclude,
+		arg.DownloadWorkers,
+		arg.UploadWorkers,
+		arg.MaxDownloadRate,
+		arg.MaxUploadRate,
+		arg.WorkHours,
+		arg.WorkMaxDownloadRate,
+		arg.WorkMaxUploadRate,
+		arg.SyncPairs,
+	)
+	return err
+}
+
+const upsertFailedDownload = `-- name: UpsertFailedDownload :exec
+INSERT INTO failed_downloads (drive_id, path, error, attempts, last_attempt)
+VALUES (?, ?, ?, 1, ?)
+ON CONFLICT (drive_id) DO UPDATE SET path         = EXCLUDED.path,
+                                     error        = EXCLUDED.error,
+                                     attempts     = failed_downloads.attempts + 1,
+                                     last_attempt = EXCLUDED.last_attempt
+`
+
+type UpsertFailedDownloadParams struct {
+	DriveID     string `json:"drive_id"`
+	Path        string `json:"path"`
+	Error       string `json:"error"`
+	LastAttempt int64  `json:"last_attempt"`
+}
+
+func (q *Queries) UpsertFailedDownload(ctx context.Context, arg UpsertFailedDownloadParams) error {
+	_, err := q.db.ExecContext(ctx, upsertFailedDownload,
+		arg.DriveID,
+		arg.Path,
+		arg.Error,
+		arg.LastAttempt,
+	)
 	return err
 }
 
 const upsertFile = `-- name: UpsertFile :exec
-INSERT INTO files (path, drive_id, content_hash, last_modified)
-VALUES (?, ?, ?, ?)
+INSERT INTO files (path, drive_id, content_hash, last_modified, mime_type, head_revision, remote_version,
+                   export_format, remote_md5)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT (path)
-    DO UPDATE SET content_hash  = EXCLUDED.content_hash,
-                  last_modified = EXCLUDED.last_modified
+    DO UPDATE SET drive_id       = EXCLUDED.drive_id,
+                  content_hash   = EXCLUDED.content_hash,
+                  last_modified  = EXCLUDED.last_modified,
+                  mime_type      = EXCLUDED.mime_type,
+                  head_revision  = EXCLUDED.head_revision,
+                  remote_version = EXCLUDED.remote_version,
+                  export_format  = EXCLUDED.export_format,
+                  remote_md5     = EXCLUDED.remote_md5
 `
 
 type UpsertFileParams struct {
-	Path         string `json:"path"`
-	DriveID      string `json:"drive_id"`
-	ContentHash  []byte `json:"content_hash"`
-	LastModified int64  `json:"last_modified"`
+	Path          string `json:"path"`
+	DriveID       string `json:"drive_id"`
+	ContentHash   []byte `json:"content_hash"`
+	LastModified  int64  `json:"last_modified"`
+	MimeType      string `json:"mime_type"`
+	HeadRevision  string `json:"head_revision"`
+	RemoteVersion int64  `json:"remote_version"`
+	ExportFormat  string `json:"export_format"`
+	RemoteMd5     string `json:"remote_md5"`
 }
 
 func (q *Queries) UpsertFile(ctx context.Context, arg UpsertFileParams) error {
@@ -194,6 +954,101 @@ func (q *Queries) UpsertFile(ctx context.Context, arg UpsertFileParams) error {
 		arg.DriveID,
 		arg.ContentHash,
 		arg.LastModified,
+		arg.MimeType,
+		arg.HeadRevision,
+		arg.RemoteVersion,
+		arg.ExportFormat,
+		arg.RemoteMd5,
+	)
+	return err
+}
+
+const upsertInitialSyncFile = `-- name: UpsertInitialSyncFile :exec
+INSERT INTO initial_sync_files (drive_id, path, head_revision, remote_version, content_hash, status)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (drive_id) DO UPDATE SET path           = EXCLUDED.path,
+                                     head_revision  = EXCLUDED.head_revision,
+                                     remote_version = EXCLUDED.remote_version,
+                                     content_hash   = EXCLUDED.content_hash,
+                                     status         = EXCLUDED.status
+`
+
+type UpsertInitialSyncFileParams struct {
+	DriveID       string `json:"drive_id"`
+	Path          string `json:"path"`
+	HeadRevision  string `json:"head_revision"`
+	RemoteVersion int64  `json:"remote_version"`
+	ContentHash   []byte `json:"content_hash"`
+	Status        string `json:"status"`
+}
+
+func (q *Queries) UpsertInitialSyncFile(ctx context.Context, arg UpsertInitialSyncFileParams) error {
+	_, err := q.db.ExecContext(ctx, upsertInitialSyncFile,
+		arg.DriveID,
+		arg.Path,
+		arg.HeadRevision,
+		arg.RemoteVersion,
+		arg.ContentHash,
+		arg.Status,
+	)
+	return err
+}
+
+const upsertSharedDrive = `-- name: UpsertSharedDrive :exec
+INSERT INTO shared_drives (drive_id, name, local_dir, page_token)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (drive_id) DO UPDATE SET name       = EXCLUDED.name,
+                                     local_dir  = EXCLUDED.local_dir,
+                                     page_token = EXCLUDED.page_token
+`
+
+type UpsertSharedDriveParams struct {
+	DriveID   string `json:"drive_id"`
+	Name      string `json:"name"`
+	LocalDir  string `json:"local_dir"`
+	PageToken string `json:"page_token"`
+}
+
+func (q *Queries) UpsertSharedDrive(ctx context.Context, arg UpsertSharedDriveParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSharedDrive,
+		arg.DriveID,
+		arg.Name,
+		arg.LocalDir,
+		arg.PageToken,
+	)
+	return err
+}
+
+const upsertUpload = `-- name: UpsertUpload :exec
+INSERT INTO uploads (path, drive_id, session_uri, size, content_hash, uploaded, started_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (path) DO UPDATE SET drive_id     = EXCLUDED.drive_id,
+                                 session_uri  = EXCLUDED.session_uri,
+                                 size         = EXCLUDED.size,
+                                 content_hash = EXCLUDED.content_hash,
+                                 uploaded     = EXCLUDED.uploaded,
+                                 started_at   = EXCLUDED.started_at
+`
+
+type UpsertUploadParams struct {
+	Path        string `json:"path"`
+	DriveID     string `json:"drive_id"`
+	SessionUri  string `json:"session_uri"`
+	Size        int64  `json:"size"`
+	ContentHash []byte `json:"content_hash"`
+	Uploaded    int64  `json:"uploaded"`
+	StartedAt   int64  `json:"started_at"`
+}
+
+func (q *Queries) UpsertUpload(ctx context.Context, arg UpsertUploadParams) error {
+	_, err := q.db.ExecContext(ctx, upsertUpload,
+		arg.Path,
+		arg.DriveID,
+		arg.SessionUri,
+		arg.Size,
+		arg.ContentHash,
+		arg.Uploaded,
+		arg.StartedAt,
 	)
 	return err
 }
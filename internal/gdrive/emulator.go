@@ -0,0 +1,370 @@
+package gdrive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	inParentsTerm = regexp.MustCompile(`^'([^']+)' in parents$`)
+	contentRange  = regexp.MustCompile(`^bytes (?:(\d+)-(\d+)|\*)/(\d+)$`)
+)
+
+// Emulator serves the endpoints of the Drive v3 REST API that park uses from a Fake, so the CLI can be run end to end
+// without a Google account. Requests are not authenticated, any token is accepted.
+type Emulator struct {
+	fake   *Fake
+	server *httptest.Server
+}
+
+// NewEmulator starts an Emulator serving fake. It must be closed once it is no longer used.
+func NewEmulator(fake *Fake) *Emulator {
+	e := &Emulator{fake: fake}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /drive/v3/files", e.listFiles)
+	mux.HandleFunc("GET /drive/v3/files/{id}", e.getFile)
+	mux.HandleFunc("GET /drive/v3/files/{id}/export", e.exportFile)
+	mux.HandleFunc("POST /drive/v3/files", e.createFile)
+	mux.HandleFunc("PATCH /drive/v3/files/{id}", e.updateFile)
+	mux.HandleFunc("POST /upload/drive/v3/files", e.uploadFile)
+	mux.HandleFunc("PATCH /upload/drive/v3/files/{id}", e.uploadFile)
+	mux.HandleFunc("PUT /upload/sessions/{session}", e.uploadChunk)
+	mux.HandleFunc("GET /drive/v3/changes/startPageToken", e.startPageToken)
+	mux.HandleFunc("GET /drive/v3/changes", e.listChanges)
+	mux.HandleFunc("GET /drive/v3/drives", e.listDrives)
+	e.server = httptest.NewServer(mux)
+	return e
+}
+
+// Endpoint returns the base URL of the emulated API, to be passed to option.WithEndpoint.
+func (e *Emulator) Endpoint() string {
+	return e.server.URL + "/drive/v3/"
+}
+
+// Close shuts the Emulator down.
+func (e *Emulator) Close() {
+	e.server.Close()
+}
+
+// listFiles lists the children of a folder or the files shared with the user. Only queries of the form
+// "'<id>' in parents and trashed=false" and "sharedWithMe=true and trashed=false" are supported.
+func (e *Emulator) listFiles(w http.ResponseWriter, r *http.Request) {
+	folderID, sharedWithMe := "", false
+	for _, term := range strings.Split(r.URL.Query().Get("q"), " and ") {
+		term = strings.ReplaceAll(strings.TrimSpace(term), " = ", "=")
+		if m := inParentsTerm.FindStringSubmatch(term); m != nil {
+			folderID = m[1]
+			continue
+		}
+		switch term {
+		case "trashed=false":
+		case "sharedWithMe=true":
+			sharedWithMe = true
+		default:
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Unsupported query term: " + term})
+			return
+		}
+	}
+
+	var files []*drive.File
+	var err error
+	switch {
+	case folderID != "":
+		files, err = e.fake.ListChildren(r.Context(), folderID, r.URL.Query().Get("driveId"), "")
+	case sharedWithMe:
+		files, err = e.fake.ListSharedWithMe(r.Context(), "")
+	default:
+		err = &googleapi.Error{Code: http.StatusBadRequest, Message: "Listing all files is not supported"}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, next, err := paginate(files, r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &drive.FileList{Files: page, NextPageToken: next})
+}
+
+// paginate returns the page of files selected by the pageToken and pageSize parameters and the token of the next
+// page. Page tokens are the offset of the page.
+func paginate(files []*drive.File, params url.Values) ([]*drive.File, string, error) {
+	offset, size := 0, len(files)
+	var err error
+	if t := params.Get("pageToken"); t != "" {
+		if offset, err = strconv.Atoi(t); err != nil || offset < 0 || offset > len(files) {
+			return nil, "", &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid page token"}
+		}
+	}
+	if s := params.Get("pageSize"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || size <= 0 {
+			return nil, "", &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid page size"}
+		}
+	}
+	end := min(offset+size, len(files))
+	next := ""
+	if end < len(files) {
+		next = strconv.Itoa(end)
+	}
+	return files[offset:end], next, nil
+}
+
+// getFile returns the metadata of a file or, with alt=media, its content. A range of the form "bytes=<offset>-" is
+// honored.
+func (e *Emulator) getFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if r.URL.Query().Get("alt") != "media" {
+		f, err := e.fake.Get(r.Context(), id, "")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, f)
+		return
+	}
+
+	var offset int64
+	if rng := r.Header.Get("Range"); rng != "" {
+		var err error
+		offset, err = strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"), 10, 64)
+		if err != nil {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Unsupported range: " + rng})
+			return
+		}
+	}
+	res, err := e.fake.Download(r.Context(), id, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResponse(w, res)
+}
+
+func (e *Emulator) exportFile(w http.ResponseWriter, r *http.Request) {
+	res, err := e.fake.Export(r.Context(), r.PathValue("id"), r.URL.Query().Get("mimeType"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResponse(w, res)
+}
+
+func (e *Emulator) createFile(w http.ResponseWriter, r *http.Request) {
+	var metadata drive.File
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid metadata: " + err.Error()})
+		return
+	}
+	f, err := e.fake.Create(r.Context(), &metadata, nil, "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// updateFile renames or trashes a file. Moving it with addParents replaces all of its parents.
+func (e *Emulator) updateFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var metadata drive.File
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid metadata: " + err.Error()})
+		return
+	}
+
+	var f *drive.File
+	var err error
+	if parentID := r.URL.Query().Get("addParents"); parentID != "" && !metadata.Trashed {
+		if f, err = e.fake.Get(r.Context(), id, ""); err != nil {
+			writeError(w, err)
+			return
+		}
+		name := metadata.Name
+		if name == "" {
+			name = f.Name
+		}
+		if err = e.fake.Move(r.Context(), id, name, parentID); err == nil {
+			f, err = e.fake.Get(r.Context(), id, "")
+		}
+	} else {
+		f, err = e.fake.Update(r.Context(), id, &metadata, nil, "")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// uploadFile creates a file, or replaces the content of the file with the ID in the path, with a multipart upload or
+// starts a resumable upload session for it.
+func (e *Emulator) uploadFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch uploadType := r.URL.Query().Get("uploadType"); uploadType {
+	case "multipart":
+		metadata, media, err := readMultipart(r)
+		if err != nil {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+		var f *drive.File
+		if id == "" {
+			f, err = e.fake.Create(r.Context(), metadata, media, "")
+		} else {
+			f, err = e.fake.Update(r.Context(), id, metadata, media, "")
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, f)
+	case "resumable":
+		var metadata drive.File
+		if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid metadata: " + err.Error()})
+			return
+		}
+		size, err := strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+		if err != nil {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Missing upload content length"})
+			return
+		}
+		session, err := e.fake.StartUpload(r.Context(), id, &metadata, size, "")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Location", e.server.URL+"/upload/sessions/"+url.PathEscape(session))
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Unsupported upload type: " + uploadType})
+	}
+}
+
+// readMultipart returns the metadata and the content of a multipart upload.
+func readMultipart(r *http.Request) (*drive.File, io.Reader, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid content type: %w", err)
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing metadata: %w", err)
+	}
+	var metadata drive.File
+	if err = json.NewDecoder(part).Decode(&metadata); err != nil {
+		return nil, nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	media, err := mr.NextPart()
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing media: %w", err)
+	}
+	return &metadata, media, nil
+}
+
+// uploadChunk receives a chunk of a resumable upload, or reports the status of the upload if the chunk is empty and
+// its range is "bytes */<size>".
+func (e *Emulator) uploadChunk(w http.ResponseWriter, r *http.Request) {
+	m := contentRange.FindStringSubmatch(r.Header.Get("Content-Range"))
+	if m == nil {
+		writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid content range"})
+		return
+	}
+	size, _ := strconv.ParseInt(m[3], 10, 64)
+
+	var f *drive.File
+	var received int64
+	var err error
+	if m[1] == "" {
+		f, received, err = e.fake.UploadStatus(r.Context(), r.PathValue("session"), size)
+	} else {
+		offset, _ := strconv.ParseInt(m[1], 10, 64)
+		chunk, errRead := io.ReadAll(r.Body)
+		if errRead != nil {
+			writeError(w, errRead)
+			return
+		}
+		f, received, err = e.fake.UploadChunk(r.Context(), r.PathValue("session"), chunk, offset, size)
+	}
+	switch {
+	case err != nil:
+		writeError(w, err)
+	case f != nil:
+		writeJSON(w, http.StatusOK, f)
+	default:
+		if received > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", received-1))
+		}
+		w.WriteHeader(statusResumeIncomplete)
+	}
+}
+
+func (e *Emulator) startPageToken(w http.ResponseWriter, r *http.Request) {
+	token, err := e.fake.StartPageToken(r.Context(), r.URL.Query().Get("driveId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &drive.StartPageToken{StartPageToken: token})
+}
+
+func (e *Emulator) listChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := e.fake.Changes(r.Context(), r.URL.Query().Get("pageToken"), r.URL.Query().Get("driveId"), "")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (e *Emulator) listDrives(w http.ResponseWriter, r *http.Request) {
+	drives, err := e.fake.ListSharedDrives(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &drive.DriveList{Drives: drives})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeResponse(w http.ResponseWriter, res *http.Response) {
+	defer res.Body.Close()
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+// writeError writes err in the format of errors of the Drive API. Errors that are not API errors are internal
+// server errors.
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &googleapi.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+	}
+	body := map[string]any{"code": apiErr.Code, "message": apiErr.Message, "errors": apiErr.Errors}
+	writeJSON(w, apiErr.Code, map[string]any{"error": body})
+}
@@ -0,0 +1,618 @@
+package gdrive
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// FakeOwner is the email address of the user of a Fake, who owns all files that are not shared with them
+	FakeOwner = "me@example.com"
+
+	rootAlias        = "root"
+	fakeRootID       = "my-drive"
+	folderMimeType   = "application/vnd.google-apps.folder"
+	shortcutMimeType = "application/vnd.google-apps.shortcut"
+	googleAppsPrefix = "application/vnd.google-apps."
+	defaultMimeType  = "application/octet-stream"
+	fakeUploadPrefix = "fake-upload:"
+)
+
+var _ Remote = (*Fake)(nil)
+
+// Fake is an in-memory Remote for tests of the sync without a Google account. It holds My Drive, shared drives and
+// files shared with the user, records every change in a change log and fails calls with injected errors. Fields are
+// ignored, files are always returned with all of their metadata. Fake is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	files   map[string]*fakeFile
+	drives  []*drive.Drive
+	changes []string
+	uploads map[string]*fakeUpload
+	errs    map[string][]error
+	nextID  int
+	now     time.Time
+}
+
+type fakeFile struct {
+	meta     drive.File
+	content  []byte
+	revision int
+	removed  bool
+}
+
+type fakeUpload struct {
+	id       string
+	metadata *drive.File
+	size     int64
+	content  []byte
+	uploaded *drive.File
+}
+
+// NewFake returns a Fake with an empty My Drive.
+func NewFake() *Fake {
+	f := &Fake{
+		files:   map[string]*fakeFile{},
+		uploads: map[string]*fakeUpload{},
+		errs:    map[string][]error{},
+		now:     time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	f.files[fakeRootID] = &fakeFile{meta: drive.File{Id: fakeRootID, Name: "My Drive", MimeType: folderMimeType}}
+	return f
+}
+
+// RootID returns the ID of the root folder of My Drive.
+func (f *Fake) RootID() string {
+	return fakeRootID
+}
+
+// InjectError makes the next call of the Remote method with the given name, e.g. "Download", fail with err. Errors
+// injected for the same method are returned by consecutive calls.
+func (f *Fake) InjectError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[method] = append(f.errs[method], err)
+}
+
+// AddFolder creates a folder in the folder with the ID parentID and returns its ID.
+func (f *Fake) AddFolder(parentID, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.add(drive.File{Name: name, MimeType: folderMimeType, Parents: []string{parentID}}, nil)
+}
+
+// AddFile creates a file with the given content in the folder with the ID parentID and returns its ID.
+func (f *Fake) AddFile(parentID, name string, content []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.add(drive.File{Name: name, MimeType: defaultMimeType, Parents: []string{parentID}}, content)
+}
+
+// AddDocument creates a Google document of mimeType in the folder with the ID parentID and returns its ID. Exports
+// of the document return content, whatever format they are requested in.
+func (f *Fake) AddDocument(parentID, name, mimeType string, content []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.add(drive.File{Name: name, MimeType: mimeType, Parents: []string{parentID}}, content)
+}
+
+// AddShortcut creates a shortcut to the file with the ID targetID in the folder with the ID parentID and returns its
+// ID.
+func (f *Fake) AddShortcut(parentID, name, targetID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	target := f.files[targetID]
+	details := &drive.FileShortcutDetails{TargetId: targetID}
+	if target != nil {
+		details.TargetMimeType = target.meta.MimeType
+	}
+	return f.add(
+		drive.File{Name: name, MimeType: shortcutMimeType, Parents: []string{parentID}, ShortcutDetails: details},
+		nil,
+	)
+}
+
+// AddSharedDrive creates a shared drive and returns its ID, which is the ID of its root folder as well.
+func (f *Fake) AddSharedDrive(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := fmt.Sprintf("drive-%d", f.nextID)
+	f.drives = append(f.drives, &drive.Drive{Id: id, Name: name})
+	f.files[id] = &fakeFile{meta: drive.File{Id: id, Name: name, MimeType: folderMimeType, DriveId: id}}
+	return id
+}
+
+// AddSharedWithMe creates a file with the given content that owner shared with the user for viewing and returns its
+// ID. The file has no parents, as the folder it is located in is not shared with the user.
+func (f *Fake) AddSharedWithMe(name, owner string, content []byte) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.add(drive.File{Name: name, MimeType: defaultMimeType}, content)
+	ff := f.files[id]
+	ff.meta.SharedWithMeTime = f.now.Format(time.RFC3339)
+	ff.meta.Owners = []*drive.User{{EmailAddress: owner}}
+	ff.meta.Capabilities = &drive.FileCapabilities{CanEdit: false}
+	return id
+}
+
+// SetContent replaces the content of the file with the given ID, as if it was edited remotely.
+func (f *Fake) SetContent(id string, content []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff, err := f.lookup(id)
+	if err != nil {
+		return err
+	}
+	f.setContent(ff, content)
+	f.record(ff)
+	return nil
+}
+
+// Delete removes the file with the given ID permanently, as if it was deleted remotely.
+func (f *Fake) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff, err := f.lookup(id)
+	if err != nil {
+		return err
+	}
+	ff.removed = true
+	f.record(ff)
+	return nil
+}
+
+// File returns a copy of the file with the given ID, including trashed files.
+func (f *Fake) File(id string) (*drive.File, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, false
+	}
+	return f.snapshot(ff), true
+}
+
+// Content returns the content of the file with the given ID.
+func (f *Fake) Content(id string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, false
+	}
+	return bytes.Clone(ff.content), true
+}
+
+// Find returns the ID of the file that is not trashed with the given name in the folder with the ID parentID.
+func (f *Fake) Find(parentID, name string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if parentID == rootAlias {
+		parentID = fakeRootID
+	}
+	for id, ff := range f.files {
+		if !ff.removed && !ff.meta.Trashed && ff.meta.Name == name && slices.Contains(ff.meta.Parents, parentID) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func (f *Fake) Get(_ context.Context, id, _ string) (*drive.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Get"); err != nil {
+		return nil, err
+	}
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.snapshot(ff), nil
+}
+
+func (f *Fake) ListChildren(_ context.Context, folderID, _, _ string) ([]*drive.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("ListChildren"); err != nil {
+		return nil, err
+	}
+	if folderID == rootAlias {
+		folderID = fakeRootID
+	}
+	if _, err := f.lookup(folderID); err != nil {
+		return nil, err
+	}
+	return f.list(func(ff *fakeFile) bool { return slices.Contains(ff.meta.Parents, folderID) }), nil
+}
+
+func (f *Fake) ListSharedWithMe(_ context.Context, _ string) ([]*drive.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("ListSharedWithMe"); err != nil {
+		return nil, err
+	}
+	return f.list(func(ff *fakeFile) bool { return ff.meta.SharedWithMeTime != "" }), nil
+}
+
+func (f *Fake) ListSharedDrives(_ context.Context) ([]*drive.Drive, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("ListSharedDrives"); err != nil {
+		return nil, err
+	}
+	drives := make([]*drive.Drive, len(f.drives))
+	for i, d := range f.drives {
+		drives[i] = &drive.Drive{Id: d.Id, Name: d.Name}
+	}
+	return drives, nil
+}
+
+func (f *Fake) Download(_ context.Context, id string, offset int64) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Download"); err != nil {
+		return nil, err
+	}
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(ff.meta.MimeType, googleAppsPrefix) {
+		return nil, &googleapi.Error{
+			Code:    http.StatusForbidden,
+			Message: "Only files with binary content can be downloaded",
+			Errors:  []googleapi.ErrorItem{{Reason: "fileNotDownloadable"}},
+		}
+	}
+	if offset == 0 {
+		return response(http.StatusOK, ff.content), nil
+	}
+	if offset >= int64(len(ff.content)) {
+		return nil, &googleapi.Error{Code: http.StatusRequestedRangeNotSatisfiable, Message: "Range not satisfiable"}
+	}
+	return response(http.StatusPartialContent, ff.content[offset:]), nil
+}
+
+func (f *Fake) Export(_ context.Context, id, _ string) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Export"); err != nil {
+		return nil, err
+	}
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(ff.meta.MimeType, googleAppsPrefix) {
+		return nil, &googleapi.Error{Code: http.StatusForbidden, Message: "Export only supports Docs Editors files"}
+	}
+	return response(http.StatusOK, ff.content), nil
+}
+
+func (f *Fake) Create(_ context.Context, metadata *drive.File, media io.Reader, _ string) (*drive.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Create"); err != nil {
+		return nil, err
+	}
+	content, err := readMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	return f.create(metadata, content)
+}
+
+func (f *Fake) Update(
+	_ context.Context,
+	id string,
+	metadata *drive.File,
+	media io.Reader,
+	_ string,
+) (*drive.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Update"); err != nil {
+		return nil, err
+	}
+	content, err := readMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	return f.update(id, metadata, content, media != nil)
+}
+
+func (f *Fake) Move(_ context.Context, id, name, parentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Move"); err != nil {
+		return err
+	}
+	ff, err := f.lookup(id)
+	if err != nil {
+		return err
+	}
+	if parentID != "" {
+		parent, err := f.lookup(parentID)
+		if err != nil {
+			return err
+		}
+		ff.meta.Parents = []string{parent.meta.Id}
+		ff.meta.DriveId = parent.meta.DriveId
+	}
+	ff.meta.Name = name
+	f.record(ff)
+	return nil
+}
+
+func (f *Fake) Trash(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Trash"); err != nil {
+		return err
+	}
+	_, err := f.update(id, &drive.File{Trashed: true}, nil, false)
+	return err
+}
+
+func (f *Fake) StartUpload(_ context.Context, id string, metadata *drive.File, size int64, _ string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("StartUpload"); err != nil {
+		return "", err
+	}
+	if id != "" {
+		if _, err := f.lookup(id); err != nil {
+			return "", err
+		}
+	}
+	f.nextID++
+	sessionURI := fakeUploadPrefix + strconv.Itoa(f.nextID)
+	f.uploads[sessionURI] = &fakeUpload{id: id, metadata: metadata, size: size}
+	return sessionURI, nil
+}
+
+func (f *Fake) UploadStatus(_ context.Context, sessionURI string, _ int64) (*drive.File, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("UploadStatus"); err != nil {
+		return nil, 0, err
+	}
+	u, ok := f.uploads[sessionURI]
+	if !ok {
+		return nil, 0, &googleapi.Error{Code: http.StatusNotFound, Message: "Upload session not found"}
+	}
+	return u.uploaded, int64(len(u.content)), nil
+}
+
+func (f *Fake) UploadChunk(
+	_ context.Context,
+	sessionURI string,
+	chunk []byte,
+	offset, size int64,
+) (*drive.File, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("UploadChunk"); err != nil {
+		return nil, 0, err
+	}
+	u, ok := f.uploads[sessionURI]
+	if !ok {
+		return nil, 0, &googleapi.Error{Code: http.StatusNotFound, Message: "Upload session not found"}
+	}
+	if size != u.size || offset != int64(len(u.content)) || offset+int64(len(chunk)) > size {
+		return nil, 0, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid content range"}
+	}
+	u.content = append(u.content, chunk...)
+	if int64(len(u.content)) < size {
+		return nil, int64(len(u.content)), nil
+	}
+
+	var err error
+	if u.id == "" {
+		u.uploaded, err = f.create(u.metadata, u.content)
+	} else {
+		u.uploaded, err = f.update(u.id, u.metadata, u.content, true)
+	}
+	return u.uploaded, 0, err
+}
+
+func (f *Fake) StartPageToken(_ context.Context, _ string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("StartPageToken"); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(len(f.changes)), nil
+}
+
+// Changes returns all changes since pageToken in a single page. Every change carries the current state of the file.
+func (f *Fake) Changes(_ context.Context, pageToken, driveID, _ string) (*drive.ChangeList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected("Changes"); err != nil {
+		return nil, err
+	}
+	start, err := strconv.Atoi(pageToken)
+	if err != nil || start < 0 || start > len(f.changes) {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid page token"}
+	}
+
+	r := &drive.ChangeList{NewStartPageToken: strconv.Itoa(len(f.changes))}
+	for _, id := range f.changes[start:] {
+		ff := f.files[id]
+		if ff.meta.DriveId != driveID {
+			continue
+		}
+		c := &drive.Change{ChangeType: "file", FileId: id, Removed: ff.removed}
+		if !ff.removed {
+			c.File = f.snapshot(ff)
+		}
+		r.Changes = append(r.Changes, c)
+	}
+	return r, nil
+}
+
+func (f *Fake) injected(method string) error {
+	errs := f.errs[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.errs[method] = errs[1:]
+	return errs[0]
+}
+
+func (f *Fake) lookup(id string) (*fakeFile, error) {
+	if id == rootAlias {
+		id = fakeRootID
+	}
+	ff, ok := f.files[id]
+	if !ok || ff.removed {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "File not found: " + id}
+	}
+	return ff, nil
+}
+
+// add creates the file described by meta with the given content and returns its ID.
+func (f *Fake) add(meta drive.File, content []byte) string {
+	created, err := f.create(&meta, content)
+	if err != nil {
+		panic(fmt.Sprintf("could not add '%s' to fake: %s", meta.Name, err))
+	}
+	return created.Id
+}
+
+func (f *Fake) create(metadata *drive.File, content []byte) (*drive.File, error) {
+	meta := drive.File{
+		Name:            metadata.Name,
+		MimeType:        metadata.MimeType,
+		ShortcutDetails: metadata.ShortcutDetails,
+		Owners:          []*drive.User{{EmailAddress: FakeOwner}},
+		Capabilities:    &drive.FileCapabilities{CanEdit: true},
+	}
+	if meta.MimeType == "" {
+		meta.MimeType = defaultMimeType
+	}
+	for _, p := range metadata.Parents {
+		parent, err := f.lookup(p)
+		if err != nil {
+			return nil, err
+		}
+		meta.Parents = append(meta.Parents, parent.meta.Id)
+		meta.DriveId = parent.meta.DriveId
+	}
+	f.nextID++
+	meta.Id = fmt.Sprintf("file-%d", f.nextID)
+
+	ff := &fakeFile{meta: meta}
+	f.files[meta.Id] = ff
+	f.setContent(ff, content)
+	f.record(ff)
+	return f.snapshot(ff), nil
+}
+
+func (f *Fake) update(id string, metadata *drive.File, content []byte, hasContent bool) (*drive.File, error) {
+	ff, err := f.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if metadata != nil && metadata.Name != "" {
+		ff.meta.Name = metadata.Name
+	}
+	if metadata != nil && metadata.Trashed {
+		ff.meta.Trashed = true
+	}
+	if hasContent {
+		f.setContent(ff, content)
+	}
+	f.record(ff)
+	return f.snapshot(ff), nil
+}
+
+// setContent replaces the content of ff and creates a new revision of it, unless it is a folder or a shortcut.
+func (f *Fake) setContent(ff *fakeFile, content []byte) {
+	if ff.meta.MimeType == folderMimeType || ff.meta.MimeType == shortcutMimeType {
+		return
+	}
+	ff.content = bytes.Clone(content)
+	ff.revision++
+	if strings.HasPrefix(ff.meta.MimeType, googleAppsPrefix) {
+		// Google documents have no revisions, checksums or size
+		return
+	}
+	md5Sum, sha1Sum, sha256Sum := md5.Sum(content), sha1.Sum(content), sha256.Sum256(content)
+	ff.meta.HeadRevisionId = fmt.Sprintf("%s-rev-%d", ff.meta.Id, ff.revision)
+	ff.meta.Md5Checksum = hex.EncodeToString(md5Sum[:])
+	ff.meta.Sha1Checksum = hex.EncodeToString(sha1Sum[:])
+	ff.meta.Sha256Checksum = hex.EncodeToString(sha256Sum[:])
+	ff.meta.Size = int64(len(content))
+}
+
+// record bumps the version and modification time of ff and appends it to the change log.
+func (f *Fake) record(ff *fakeFile) {
+	f.now = f.now.Add(time.Second)
+	ff.meta.Version++
+	ff.meta.ModifiedTime = f.now.Format(time.RFC3339)
+	f.changes = append(f.changes, ff.meta.Id)
+}
+
+// list returns the files that are not trashed and match, ordered by name.
+func (f *Fake) list(match func(*fakeFile) bool) []*drive.File {
+	var files []*drive.File
+	for _, ff := range f.files {
+		if !ff.removed && !ff.meta.Trashed && ff.meta.Id != fakeRootID && match(ff) {
+			files = append(files, f.snapshot(ff))
+		}
+	}
+	slices.SortFunc(files, func(a, b *drive.File) int { return strings.Compare(a.Name, b.Name) })
+	return files
+}
+
+// snapshot returns a copy of the metadata of ff that the caller may modify.
+func (f *Fake) snapshot(ff *fakeFile) *drive.File {
+	meta := ff.meta
+	meta.Parents = slices.Clone(ff.meta.Parents)
+	meta.WebViewLink = "https://drive.google.com/open?id=" + ff.meta.Id
+	if ff.meta.ShortcutDetails != nil {
+		details := *ff.meta.ShortcutDetails
+		meta.ShortcutDetails = &details
+	}
+	return &meta
+}
+
+func readMedia(media io.Reader) ([]byte, error) {
+	if media == nil {
+		return nil, nil
+	}
+	content, err := io.ReadAll(media)
+	if err != nil {
+		return nil, fmt.Errorf("could not read media: %w", err)
+	}
+	return content, nil
+}
+
+func response(status int, content []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Header:        http.Header{"Content-Length": {strconv.Itoa(len(content))}},
+		Body:          io.NopCloser(bytes.NewReader(bytes.Clone(content))),
+		ContentLength: int64(len(content)),
+	}
+}
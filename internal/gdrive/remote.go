@@ -0,0 +1,59 @@
+// Package gdrive provides access to Google Drive through the Remote interface, implemented by Service for the Drive
+// API and by Fake in memory.
+package gdrive
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Remote is the part of the Drive API park syncs with. All calls support shared drives. fields selects the fields of
+// the returned files, as in the fields parameter of the Drive API, where partial responses are supported.
+type Remote interface {
+	// Get returns the file with the given ID.
+	Get(ctx context.Context, id, fields string) (*drive.File, error)
+	// ListChildren returns the files in the folder with the given ID that are not trashed. driveID is the ID of the
+	// shared drive the folder is located in, or empty for My Drive.
+	ListChildren(ctx context.Context, folderID, driveID, fields string) ([]*drive.File, error)
+	// ListSharedWithMe returns the files shared with the user that are not trashed.
+	ListSharedWithMe(ctx context.Context, fields string) ([]*drive.File, error)
+	// ListSharedDrives returns the shared drives the user is a member of.
+	ListSharedDrives(ctx context.Context) ([]*drive.Drive, error)
+
+	// Download returns the content of the file with the given ID, starting at offset. The response has the status
+	// 206 Partial Content if offset is not 0 and the range was honored.
+	Download(ctx context.Context, id string, offset int64) (*http.Response, error)
+	// Export returns the content of the Google document with the given ID converted to mimeType.
+	Export(ctx context.Context, id, mimeType string) (*http.Response, error)
+
+	// Create creates the file described by metadata with the content read from media, which is nil for folders.
+	Create(ctx context.Context, metadata *drive.File, media io.Reader, fields string) (*drive.File, error)
+	// Update changes the file with the given ID according to metadata and replaces its content with media, unless
+	// media is nil.
+	Update(ctx context.Context, id string, metadata *drive.File, media io.Reader, fields string) (*drive.File, error)
+	// Move renames the file with the given ID to name and, unless parentID is empty, moves it into the folder with the
+	// ID parentID, removing it from its other parents.
+	Move(ctx context.Context, id, name, parentID string) error
+	// Trash moves the file with the given ID to the trash.
+	Trash(ctx context.Context, id string) error
+
+	// StartUpload starts a resumable upload of size bytes as new content of the file with the given ID, or as the new
+	// file described by metadata if id is empty, and returns the URI of the upload session.
+	StartUpload(ctx context.Context, id string, metadata *drive.File, size int64, fields string) (string, error)
+	// UploadStatus returns the uploaded file if the upload session is complete and otherwise the number of bytes it
+	// received so far.
+	UploadStatus(ctx context.Context, sessionURI string, size int64) (*drive.File, int64, error)
+	// UploadChunk sends chunk, which starts at offset of the content of the given size, to the upload session. It
+	// returns the uploaded file if the upload is complete and otherwise the number of bytes received so far.
+	UploadChunk(ctx context.Context, sessionURI string, chunk []byte, offset, size int64) (*drive.File, int64, error)
+
+	// StartPageToken returns the page token of the current state of the shared drive with the given ID, or of My
+	// Drive if driveID is empty.
+	StartPageToken(ctx context.Context, driveID string) (string, error)
+	// Changes returns a page of the changes of the shared drive with the given ID, or of My Drive if driveID is empty,
+	// since pageToken. fields selects the fields of the changed files.
+	Changes(ctx context.Context, pageToken, driveID, fields string) (*drive.ChangeList, error)
+}
@@ -0,0 +1,276 @@
+package gdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	pageSize = 1000
+
+	// statusResumeIncomplete is the status Drive responds with to chunks of an upload that is not complete yet
+	statusResumeIncomplete = 308
+)
+
+var _ Remote = (*Service)(nil)
+
+// Service is the Remote backed by the Drive API.
+type Service struct {
+	drv *drive.Service
+	// client sends the requests the Drive service does not support, e.g. continuing a resumable upload after a restart
+	client *http.Client
+}
+
+// NewService returns the Remote backed by drv, which sends its requests with client.
+func NewService(drv *drive.Service, client *http.Client) *Service {
+	return &Service{drv: drv, client: client}
+}
+
+func (s *Service) Get(ctx context.Context, id, fields string) (*drive.File, error) {
+	return s.drv.Files.Get(id).SupportsAllDrives(true).Fields(googleapi.Field(fields)).Context(ctx).Do()
+}
+
+func (s *Service) ListChildren(ctx context.Context, folderID, driveID, fields string) ([]*drive.File, error) {
+	call := s.drv.Files.List().
+		Q(fmt.Sprintf("'%s' in parents and trashed=false", folderID)).
+		Fields(googleapi.Field("nextPageToken, files(" + fields + ")")).
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true).
+		PageSize(pageSize)
+	if driveID != "" {
+		call = call.Corpora("drive").DriveId(driveID)
+	}
+	return collectFiles(ctx, call)
+}
+
+func (s *Service) ListSharedWithMe(ctx context.Context, fields string) ([]*drive.File, error) {
+	call := s.drv.Files.List().
+		Q("sharedWithMe=true and trashed=false").
+		Fields(googleapi.Field("nextPageToken, files(" + fields + ")")).
+		PageSize(pageSize)
+	return collectFiles(ctx, call)
+}
+
+func collectFiles(ctx context.Context, call *drive.FilesListCall) ([]*drive.File, error) {
+	var files []*drive.File
+	err := call.Pages(
+		ctx, func(r *drive.FileList) error {
+			files = append(files, r.Files...)
+			return nil
+		},
+	)
+	return files, err
+}
+
+func (s *Service) ListSharedDrives(ctx context.Context) ([]*drive.Drive, error) {
+	var drives []*drive.Drive
+	err := s.drv.Drives.List().
+		Fields("nextPageToken, drives(id, name)").
+		PageSize(100).
+		Pages(
+			ctx, func(r *drive.DriveList) error {
+				drives = append(drives, r.Drives...)
+				return nil
+			},
+		)
+	return drives, err
+}
+
+func (s *Service) Download(ctx context.Context, id string, offset int64) (*http.Response, error) {
+	call := s.drv.Files.Get(id).SupportsAllDrives(true).Context(ctx)
+	if offset > 0 {
+		call.Header().Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return call.Download()
+}
+
+func (s *Service) Export(ctx context.Context, id, mimeType string) (*http.Response, error) {
+	return s.drv.Files.Export(id, mimeType).Context(ctx).Download()
+}
+
+func (s *Service) Create(
+	ctx context.Context,
+	metadata *drive.File,
+	media io.Reader,
+	fields string,
+) (*drive.File, error) {
+	call := s.drv.Files.Create(metadata).SupportsAllDrives(true).Fields(googleapi.Field(fields)).Context(ctx)
+	if media != nil {
+		call = call.Media(media)
+	}
+	return call.Do()
+}
+
+func (s *Service) Update(
+	ctx context.Context,
+	id string,
+	metadata *drive.File,
+	media io.Reader,
+	fields string,
+) (*drive.File, error) {
+	call := s.drv.Files.Update(id, metadata).SupportsAllDrives(true).Fields(googleapi.Field(fields)).Context(ctx)
+	if media != nil {
+		call = call.Media(media)
+	}
+	return call.Do()
+}
+
+func (s *Service) Move(ctx context.Context, id, name, parentID string) error {
+	call := s.drv.Files.Update(id, &drive.File{Name: name}).SupportsAllDrives(true).Fields("id").Context(ctx)
+	if parentID != "" {
+		current, err := s.Get(ctx, id, "id, parents")
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(current.Parents, parentID) {
+			call = call.AddParents(parentID).RemoveParents(strings.Join(current.Parents, ","))
+		}
+	}
+	_, err := call.Do()
+	return err
+}
+
+func (s *Service) Trash(ctx context.Context, id string) error {
+	_, err := s.Update(ctx, id, &drive.File{Trashed: true}, nil, "id")
+	return err
+}
+
+func (s *Service) StartUpload(
+	ctx context.Context,
+	id string,
+	metadata *drive.File,
+	size int64,
+	fields string,
+) (string, error) {
+	method, endpoint := http.MethodPost, s.uploadEndpoint()
+	if id != "" {
+		method, endpoint = http.MethodPatch, endpoint+"/"+url.PathEscape(id)
+	}
+	params := url.Values{
+		"uploadType":        {"resumable"},
+		"supportsAllDrives": {"true"},
+		"fields":            {fields},
+	}
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+"?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if err = googleapi.CheckResponse(res); err != nil {
+		return "", err
+	}
+	sessionURI := res.Header.Get("Location")
+	if sessionURI == "" {
+		return "", fmt.Errorf("response has no session URI")
+	}
+	return sessionURI, nil
+}
+
+func (s *Service) UploadStatus(ctx context.Context, sessionURI string, size int64) (*drive.File, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURI, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return uploadResponse(res)
+}
+
+func (s *Service) UploadChunk(
+	ctx context.Context,
+	sessionURI string,
+	chunk []byte,
+	offset, size int64,
+) (*drive.File, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionURI, bytes.NewReader(chunk))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(chunk))-1, size))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return uploadResponse(res)
+}
+
+// uploadResponse interprets a response of an upload session. It returns the uploaded file if the upload is complete
+// and otherwise the number of bytes Drive received so far.
+func uploadResponse(res *http.Response) (*drive.File, int64, error) {
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case statusResumeIncomplete:
+		// The range of received bytes, e.g. "bytes=0-1048575", is missing if nothing was received yet
+		_, last, ok := strings.Cut(res.Header.Get("Range"), "-")
+		if !ok {
+			return nil, 0, nil
+		}
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid range '%s': %w", res.Header.Get("Range"), err)
+		}
+		return nil, n + 1, nil
+	case http.StatusOK, http.StatusCreated:
+		var f drive.File
+		if err := json.NewDecoder(res.Body).Decode(&f); err != nil {
+			return nil, 0, fmt.Errorf("could not decode uploaded file: %w", err)
+		}
+		return &f, 0, nil
+	}
+	return nil, 0, googleapi.CheckResponse(res)
+}
+
+// uploadEndpoint returns the URL files are uploaded to, derived from the base path of the Drive service.
+func (s *Service) uploadEndpoint() string {
+	return strings.Replace(s.drv.BasePath, "/drive/v3/", "/upload/drive/v3/", 1) + "files"
+}
+
+func (s *Service) StartPageToken(ctx context.Context, driveID string) (string, error) {
+	call := s.drv.Changes.GetStartPageToken().Context(ctx)
+	if driveID != "" {
+		call = call.DriveId(driveID).SupportsAllDrives(true)
+	}
+	token, err := call.Do()
+	if err != nil {
+		return "", err
+	}
+	return token.StartPageToken, nil
+}
+
+func (s *Service) Changes(ctx context.Context, pageToken, driveID, fields string) (*drive.ChangeList, error) {
+	call := s.drv.Changes.List(pageToken).
+		Fields(googleapi.Field("nextPageToken, newStartPageToken, changes(changeType, fileId, removed, file(" +
+			fields + "))")).
+		IncludeRemoved(true).
+		PageSize(pageSize).
+		Context(ctx)
+	if driveID != "" {
+		call = call.DriveId(driveID).SupportsAllDrives(true).IncludeItemsFromAllDrives(true)
+	}
+	return call.Do()
+}
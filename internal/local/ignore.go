@@ -0,0 +1,226 @@
+package local
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/torfstack/park/internal/logging"
+)
+
+// IgnoreFileName is the name of the files holding gitignore-style rules for local paths that are never synced. They
+// apply to the directory they are located in and everything below it.
+const IgnoreFileName = ".parkignore"
+
+// defaultIgnoreRules are applied before the rules of all ignore files, which may override them.
+var defaultIgnoreRules = []string{
+	// Operating system metadata
+	".DS_Store",
+	"._*",
+	"Thumbs.db",
+	"desktop.ini",
+	".directory",
+	".Trash-*/",
+	// Editor swap, backup and lock files
+	"*.swp",
+	"*.swo",
+	"*~",
+	".#*",
+	"#*#",
+	".~lock.*#",
+	"~$*",
+	// Temporary and partial downloads, including those of park itself
+	"*.tmp",
+	"*.park-partial",
+	"*.part",
+	"*.crdownload",
+	// Version control and build artefacts
+	".git/",
+	"node_modules/",
+	"__pycache__/",
+	"*.pyc",
+	"*.o",
+}
+
+// ignoreRule is a single line of an ignore file.
+type ignoreRule struct {
+	// segments are the slash-separated parts of the pattern
+	segments []string
+	// anchored rules contain a slash and match paths relative to the directory of their ignore file, other rules
+	// match the name of files and directories at any depth
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// Ignorer decides which local paths are ignored according to the built-in defaults and the ignore files below the
+// root directory.
+type Ignorer struct {
+	root     string
+	defaults []ignoreRule
+
+	mu sync.Mutex
+	// rules caches the rules of the ignore file of each directory relative to the root, nil if it has none
+	rules map[string][]ignoreRule
+}
+
+func NewIgnorer(root string) *Ignorer {
+	return &Ignorer{
+		root:     root,
+		defaults: parseIgnoreRules(defaultIgnoreRules),
+		rules:    make(map[string][]ignoreRule),
+	}
+}
+
+// Ignored reports whether the path relative to the root is ignored, either by itself or because one of its parent
+// directories is ignored.
+func (i *Ignorer) Ignored(relativePath string, isDir bool) bool {
+	relativePath = filepath.ToSlash(filepath.Clean(relativePath))
+	if relativePath == "." {
+		return false
+	}
+	parts := strings.Split(relativePath, "/")
+	for n := 1; n <= len(parts); n++ {
+		if i.matches(parts[:n], n < len(parts) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoredAbs reports whether the absolute path is ignored, see Ignored. Paths outside the root are never ignored.
+func (i *Ignorer) IgnoredAbs(absolutePath string) bool {
+	rel, err := filepath.Rel(i.root, absolutePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	info, err := os.Lstat(absolutePath)
+	return i.Ignored(rel, err == nil && info.IsDir())
+}
+
+// Forget drops the cached rules of the ignore file in the directory relative to the root, e.g. because it changed.
+func (i *Ignorer) Forget(relativeDir string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.rules, filepath.ToSlash(filepath.Clean(relativeDir)))
+}
+
+// matches applies the rules to the path given by parts. Later rules take precedence over earlier ones and rules of
+// deeper ignore files take precedence over those of the ignore files above them.
+func (i *Ignorer) matches(parts []string, isDir bool) bool {
+	ignored := applyRules(i.defaults, parts, isDir, false)
+	for depth := 0; depth < len(parts); depth++ {
+		dir := "."
+		if depth > 0 {
+			dir = strings.Join(parts[:depth], "/")
+		}
+		ignored = applyRules(i.rulesOf(dir), parts[depth:], isDir, ignored)
+	}
+	return ignored
+}
+
+func applyRules(rules []ignoreRule, parts []string, isDir bool, ignored bool) bool {
+	for _, r := range rules {
+		if r.matches(parts, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (i *Ignorer) rulesOf(dir string) []ignoreRule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if rules, ok := i.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	f, err := os.Open(filepath.Join(i.root, filepath.FromSlash(dir), IgnoreFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		logging.Errorf("Could not read ignore file in %s: %s", dir, err)
+	default:
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err = scanner.Err(); err != nil {
+			logging.Errorf("Could not read ignore file in %s: %s", dir, err)
+		}
+		_ = f.Close()
+		rules = parseIgnoreRules(lines)
+	}
+	i.rules[dir] = rules
+	return rules
+}
+
+func parseIgnoreRules(lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		// A leading backslash escapes a literal # or !
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		r.segments = strings.Split(line, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matches reports whether the rule matches the path given by parts, relative to the directory of the ignore file.
+func (r ignoreRule) matches(parts []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(r.segments, parts)
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			// A trailing "/**" matches everything inside, but not the directory itself
+			return len(parts) > 0
+		}
+		for k := 0; k <= len(parts); k++ {
+			if matchSegments(pattern[1:], parts[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], parts[0])
+	return ok && matchSegments(pattern[1:], parts[1:])
+}
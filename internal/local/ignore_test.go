@@ -0,0 +1,70 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testIgnoreFile = `# comment
+*.log
+!important.log
+/build
+logs/
+docs/**/*.pdf
+**/cache
+\#hash
+\!bang
+`
+
+func TestIgnored(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, IgnoreFileName), []byte(testIgnoreFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "keep", IgnoreFileName), []byte("!*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignorer := NewIgnorer(root)
+
+	tests := []struct {
+		name  string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"pattern", "a.log", false, true},
+		{"pattern at any depth", "sub/a.log", false, true},
+		{"no match", "readme.md", false, false},
+		{"negation", "important.log", false, false},
+		{"negation at any depth", "sub/important.log", false, false},
+		{"negation in deeper ignore file", "keep/a.log", false, false},
+		{"anchored", "build", true, true},
+		{"anchored below root", "sub/build", true, false},
+		{"below ignored directory", "build/out/x.txt", false, true},
+		{"directory only", "logs", true, true},
+		{"directory only on file", "logs", false, false},
+		{"below directory only", "sub/logs/x.txt", false, true},
+		{"double star", "docs/a/b/c.pdf", false, true},
+		{"double star matching nothing", "docs/c.pdf", false, true},
+		{"double star other extension", "docs/a/c.txt", false, false},
+		{"leading double star", "x/y/cache", true, true},
+		{"leading double star at root", "cache", true, true},
+		{"comment", "# comment", false, false},
+		{"escaped hash", "#hash", false, true},
+		{"escaped exclamation mark", "!bang", false, true},
+		{"default rule", ".DS_Store", false, true},
+		{"partial download", "sub/x.park-partial", false, true},
+		{"root", ".", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ignorer.Ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Ignored(%q, %t) = %t, want %t", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
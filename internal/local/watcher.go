@@ -2,9 +2,11 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/torfstack/park/internal/logging"
@@ -12,11 +14,13 @@ import (
 
 type Watcher struct {
 	watcher  *fsnotify.Watcher
+	ignorer  *Ignorer
 	Events   chan fsnotify.Event
 	RootPath string
 }
 
-func NewWatcher(rootPath string) (*Watcher, error) {
+// NewWatcher watches rootPath and everything below it. Events of paths ignored by ignorer are not forwarded.
+func NewWatcher(rootPath string, ignorer *Ignorer) (*Watcher, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -24,15 +28,28 @@ func NewWatcher(rootPath string) (*Watcher, error) {
 
 	w := &Watcher{
 		watcher:  watcher,
+		ignorer:  ignorer,
 		Events:   make(chan fsnotify.Event),
 		RootPath: rootPath,
 	}
 
+	if err = w.addTree(rootPath); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// addTree adds path and all directories below it to the watcher.
+func (w *Watcher) addTree(path string) error {
 	// NOTE: fsnotify does not recursively watch subdirectories
-	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if info.IsDir() && w.ignorer.IgnoredAbs(path) {
+			return filepath.SkipDir
+		}
 		if info.IsDir() {
 			if err = w.addDir(path); err != nil {
 				return err
@@ -40,11 +57,16 @@ func NewWatcher(rootPath string) (*Watcher, error) {
 		}
 		return nil
 	})
-	if err != nil {
-		return nil, err
-	}
+}
 
-	return w, nil
+// removeTree removes path and all directories below it from the watcher.
+func (w *Watcher) removeTree(path string) {
+	for _, p := range w.watcher.WatchList() {
+		if p == path || strings.HasPrefix(p, path+string(filepath.Separator)) {
+			// fsnotify already stops watching directories that were removed, so errors are expected here
+			_ = w.watcher.Remove(p)
+		}
+	}
 }
 
 func (w *Watcher) addDir(path string) error {
@@ -69,9 +91,12 @@ func (w *Watcher) Close() {
 	}
 }
 
-func (w *Watcher) Run(_ context.Context) error {
+func (w *Watcher) Run(ctx context.Context) error {
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
+
 		case event, ok := <-w.watcher.Events:
 			if !ok {
 				return nil
@@ -81,13 +106,23 @@ func (w *Watcher) Run(_ context.Context) error {
 			if err != nil || relativePath == ".." || event.Name == w.RootPath {
 				continue
 			}
+			if filepath.Base(relativePath) == IgnoreFileName {
+				w.ignorer.Forget(filepath.Dir(relativePath))
+			}
+			if w.ignorer.IgnoredAbs(event.Name) {
+				continue
+			}
 
 			err = w.handle(event)
 			if err != nil {
 				return fmt.Errorf("run; could not handle event: %w", err)
 			}
 
-			w.Events <- event
+			select {
+			case w.Events <- event:
+			case <-ctx.Done():
+				return nil
+			}
 
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
@@ -101,14 +136,20 @@ func (w *Watcher) Run(_ context.Context) error {
 func (w *Watcher) handle(event fsnotify.Event) error {
 	switch {
 	case event.Has(fsnotify.Create):
-		return w.addDir(event.Name)
+		// A moved directory may contain subdirectories which have to be watched as well
+		err := w.addTree(event.Name)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
 	case event.Has(fsnotify.Write):
 		// Nothing to do yet
 	case event.Has(fsnotify.Remove):
 		// Nothing to do yet, fsnotify stops watching directories when they are removed
 	case event.Has(fsnotify.Rename):
-		// Nothing to do yet, fsnotify stops watching directories when they are removed
-		// A rename is followed by a create event
+		// A rename is followed by a create event for the new path, which is watched from then on. Watches for the
+		// old path would report events with stale paths.
+		w.removeTree(event.Name)
 	default:
 		logging.Debugf("Ignoring event: %s", event)
 	}
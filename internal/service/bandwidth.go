@@ -0,0 +1,49 @@
+package service
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/throttle"
+)
+
+// bandwidth is shared by all transfers of a profile, so the rates configured for the profile limit their sum.
+type bandwidth struct {
+	download *throttle.Bandwidth
+	upload   *throttle.Bandwidth
+}
+
+// profileKey identifies a profile among the profiles of all config directories.
+type profileKey struct {
+	configDir string
+	profile   string
+}
+
+var (
+	bandwidthsMu sync.Mutex
+	// bandwidths are the bandwidths of the profiles running in this process, the sync pairs of a profile share the
+	// bandwidth of their profile
+	bandwidths = map[profileKey]*bandwidth{}
+)
+
+func bandwidthOf(cfg config.Config) *bandwidth {
+	bandwidthsMu.Lock()
+	defer bandwidthsMu.Unlock()
+	key := profileKey{configDir: cfg.Dirs.Config, profile: cfg.Dirs.Profile}
+	b, ok := bandwidths[key]
+	if !ok {
+		b = &bandwidth{download: throttle.NewBandwidth(), upload: throttle.NewBandwidth()}
+		bandwidths[key] = b
+	}
+	return b
+}
+
+func throttleDownload(cfg config.Config, r io.Reader) io.Reader {
+	return bandwidthOf(cfg).download.Reader(r, func() int64 { return cfg.DownloadRateAt(time.Now()) })
+}
+
+func throttleUpload(cfg config.Config, r io.Reader) io.Reader {
+	return bandwidthOf(cfg).upload.Reader(r, func() int64 { return cfg.UploadRateAt(time.Now()) })
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/torfstack/park/internal/config"
+)
+
+func TestBandwidthOfIsSharedBySyncPairs(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SyncPairs = []config.SyncPair{{Folder: "A", LocalDir: t.TempDir()}, {Folder: "B", LocalDir: t.TempDir()}}
+	pairs := cfg.Pairs()
+
+	if bandwidthOf(pairs[0]) != bandwidthOf(cfg) || bandwidthOf(pairs[1]) != bandwidthOf(cfg) {
+		t.Error("sync pairs do not share the bandwidth of their profile")
+	}
+	other := testConfig(t)
+	if bandwidthOf(other) == bandwidthOf(cfg) {
+		t.Error("profiles share their bandwidth")
+	}
+}
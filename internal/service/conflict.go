@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+// resolveConflict resolves a file that changed both locally and remotely since it was last synced according to the
+// configured conflict policy and records the conflict.
+func (d *daemon) resolveConflict(
+	ctx context.Context,
+	q *sqlc.Queries,
+	known sqlc.File,
+	remote *drive.File,
+	localHash []byte,
+) error {
+	info, err := os.Stat(filepath.Join(d.cfg.LocalDir, known.Path))
+	if err != nil {
+		return fmt.Errorf("could not stat '%s': %w", known.Path, err)
+	}
+	remoteModified, _ := time.Parse(time.RFC3339, remote.ModifiedTime)
+
+	logging.Infof(
+		"Conflict: %s was changed locally and remotely since the last sync, resolving with policy '%s'",
+		known.Path, d.cfg.ConflictPolicy,
+	)
+	now := time.Now()
+	conflict := sqlc.InsertConflictParams{
+		Path:           known.Path,
+		DriveID:        known.DriveID,
+		LocalHash:      localHash,
+		LocalSize:      info.Size(),
+		LocalModified:  info.ModTime().Unix(),
+		RemoteRevision: remote.HeadRevisionId,
+		RemoteMd5:      remote.Md5Checksum,
+		RemoteSize:     remote.Size,
+		RemoteModified: remoteModified.Unix(),
+		Resolution:     string(d.cfg.ConflictPolicy),
+		DetectedAt:     now.Unix(),
+		ResolvedAt:     now.Unix(),
+	}
+
+	switch d.cfg.ConflictPolicy {
+	case config.ConflictManual:
+		logging.Infof("Run `park resolve %s` to resolve the conflict", known.Path)
+		conflict.Resolution = ""
+		conflict.ResolvedAt = 0
+	case config.ConflictPreferLocal:
+		err = d.uploadContent(ctx, q, known.Path, known.DriveID, localHash)
+	case config.ConflictPreferRemote:
+		err = d.downloadTo(ctx, q, known.Path, remote)
+	default:
+		conflict.ConflictCopy, err = d.keepBoth(ctx, q, known.Path, remote, localHash, now)
+	}
+	if err != nil {
+		return fmt.Errorf("could not resolve conflict of '%s': %w", known.Path, err)
+	}
+
+	if err = q.InsertConflict(ctx, conflict); err != nil {
+		return fmt.Errorf("could not record conflict of '%s': %w", known.Path, err)
+	}
+	return nil
+}
+
+// hasUnresolvedConflict reports whether relativePath has a conflict that waits for `park resolve`, in which case
+// it must not be synced.
+func hasUnresolvedConflict(ctx context.Context, q *sqlc.Queries, relativePath string) (bool, error) {
+	_, err := q.GetUnresolvedConflict(ctx, relativePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not look up conflicts of '%s': %w", relativePath, err)
+	}
+	logging.Infof("Skipping %s, it has an unresolved conflict", relativePath)
+	return true, nil
+}
+
+// keepBoth moves the local version of relativePath to a conflict copy, which is uploaded as a new file, and downloads
+// the remote version to relativePath. It returns the path of the conflict copy. The remote version is downloaded
+// before anything is renamed, the local version is moved back to relativePath if keeping both fails.
+func (d *daemon) keepBoth(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath string,
+	remote *drive.File,
+	localHash []byte,
+	now time.Time,
+) (string, error) {
+	copyPath, err := conflictCopyPath(d.cfg.LocalDir, relativePath, now)
+	if err != nil {
+		return "", err
+	}
+	absolutePath := filepath.Join(d.cfg.LocalDir, relativePath)
+	absoluteCopyPath := filepath.Join(d.cfg.LocalDir, copyPath)
+	// The partial suffix keeps sync from picking up the remote version before it is moved into place
+	stagedPath := absolutePath + partialSuffix
+	hash, err := d.fetchTo(ctx, stagedPath, remote)
+	if err != nil {
+		return "", &downloadError{path: relativePath, err: err}
+	}
+	if err = os.Rename(absolutePath, absoluteCopyPath); err != nil {
+		_ = os.Remove(stagedPath)
+		return "", fmt.Errorf("could not create conflict copy: %w", err)
+	}
+
+	err = os.Rename(stagedPath, absolutePath)
+	if err != nil {
+		err = fmt.Errorf("could not move remote version into place: %w", err)
+	} else if err = d.recordDownload(ctx, q, relativePath, remote, hash); err == nil {
+		err = d.uploadContent(ctx, q, copyPath, "", localHash)
+	}
+	if err != nil {
+		_ = os.Remove(stagedPath)
+		if errRestore := os.Rename(absoluteCopyPath, absolutePath); errRestore != nil {
+			logging.Errorf("Could not move conflict copy %s back to %s: %s", copyPath, relativePath, errRestore)
+		}
+		return "", err
+	}
+	return copyPath, nil
+}
+
+// conflictCopyPath returns an unused path of the form "name (conflict from <host> <date>).ext" next to relativePath.
+func conflictCopyPath(rootDir, relativePath string, now time.Time) (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown host"
+	}
+	ext := filepath.Ext(relativePath)
+	if ext == filepath.Base(relativePath) {
+		// Dotfiles like .bashrc have no extension
+		ext = ""
+	}
+	name := strings.TrimSuffix(relativePath, ext)
+	suffix := fmt.Sprintf("conflict from %s %s", host, now.Format(time.DateOnly))
+
+	candidate := fmt.Sprintf("%s (%s)%s", name, suffix, ext)
+	for i := 2; ; i++ {
+		_, err = os.Lstat(filepath.Join(rootDir, candidate))
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not check conflict copy '%s': %w", candidate, err)
+		}
+		candidate = fmt.Sprintf("%s (%s %d)%s", name, suffix, i, ext)
+	}
+}
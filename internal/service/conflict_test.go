@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/googleapi"
+)
+
+func TestKeepBothRestoresLocalVersionOnFailure(t *testing.T) {
+	forbidden := &googleapi.Error{Code: http.StatusForbidden}
+	tests := []struct {
+		name   string
+		method string
+	}{
+		{name: "download fails", method: "Download"},
+		{name: "upload fails", method: "Create"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			id := fake.AddFile(fake.RootID(), "x.txt", []byte("base"))
+			cfg := testConfig(t)
+			d := testDatabase(t, cfg)
+			if _, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil); err != nil {
+				t.Fatalf("performInitialSync() = %v", err)
+			}
+			dmn, err := newDaemon(ctx, cfg, d, fake)
+			if err != nil {
+				t.Fatalf("newDaemon() = %v", err)
+			}
+			if err = os.WriteFile(filepath.Join(cfg.LocalDir, "x.txt"), []byte("local"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err = fake.SetContent(id, []byte("remote")); err != nil {
+				t.Fatal(err)
+			}
+			remote, _ := fake.File(id)
+			localHash, err := hashFile(filepath.Join(cfg.LocalDir, "x.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fake.InjectError(tt.method, forbidden)
+			if _, err = dmn.keepBoth(ctx, d.Queries(), "x.txt", remote, localHash, time.Now()); err == nil {
+				t.Fatal("keepBoth() succeeded, want error")
+			}
+
+			assertContent(t, cfg.LocalDir, "x.txt", "local")
+			entries, err := os.ReadDir(cfg.LocalDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != "x.txt" && filepath.Ext(e.Name()) != partialSuffix {
+					t.Errorf("unexpected file %s", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		policy config.ConflictPolicy
+		local  map[string]string
+		remote map[string]string
+		// unresolved reports whether the conflict waits for `park resolve`
+		unresolved bool
+	}{
+		{
+			policy: config.ConflictKeepBoth,
+			local:  map[string]string{"A/a.txt": "a", "b.txt": "remote", "b (conflict).txt": "local"},
+			remote: map[string]string{"A/a.txt": "a", "b.txt": "remote", "b (conflict).txt": "local"},
+		},
+		{
+			policy: config.ConflictPreferLocal,
+			local:  map[string]string{"A/a.txt": "a", "b.txt": "local"},
+			remote: map[string]string{"A/a.txt": "a", "b.txt": "local"},
+		},
+		{
+			policy: config.ConflictPreferRemote,
+			local:  map[string]string{"A/a.txt": "a", "b.txt": "remote"},
+			remote: map[string]string{"A/a.txt": "a", "b.txt": "remote"},
+		},
+		{
+			policy:     config.ConflictManual,
+			local:      map[string]string{"A/a.txt": "a", "b.txt": "local"},
+			remote:     map[string]string{"A/a.txt": "a", "b.txt": "remote"},
+			unresolved: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			ids := testTree(fake)
+			cfg := testConfig(t)
+			cfg.ConflictPolicy = tt.policy
+			dmn := newTestDaemon(t, cfg, fake)
+
+			if err := os.WriteFile(filepath.Join(cfg.LocalDir, "b.txt"), []byte("local"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := fake.SetContent(ids["b.txt"], []byte("remote")); err != nil {
+				t.Fatal(err)
+			}
+			if err := dmn.syncRemoteChanges(ctx); err != nil {
+				t.Fatalf("syncRemoteChanges() = %v", err)
+			}
+
+			assertFiles(t, "local", withoutConflictSuffix(localFiles(t, cfg.LocalDir)), tt.local)
+			assertFiles(t, "remote", withoutConflictSuffix(remoteFiles(t, fake)), tt.remote)
+			conflicts, err := dmn.db.Queries().GetConflicts(ctx)
+			if err != nil || len(conflicts) != 1 {
+				t.Fatalf("GetConflicts() = %v, %v, want one conflict", conflicts, err)
+			}
+			if unresolved := conflicts[0].ResolvedAt == 0; unresolved != tt.unresolved {
+				t.Errorf("conflict unresolved = %t, want %t", unresolved, tt.unresolved)
+			}
+		})
+	}
+}
+
+// withoutConflictSuffix replaces the host and date in the names of conflict copies among files, which differ between
+// runs.
+func withoutConflictSuffix(files map[string]string) map[string]string {
+	replaced := make(map[string]string, len(files))
+	for p, content := range files {
+		if i := strings.Index(p, " (conflict from "); i >= 0 {
+			p = p[:i] + " (conflict)" + filepath.Ext(p)
+		}
+		replaced[p] = content
+	}
+	return replaced
+}
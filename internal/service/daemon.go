@@ -2,38 +2,189 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/gdrive"
 	"github.com/torfstack/park/internal/local"
 	"github.com/torfstack/park/internal/logging"
 )
 
-func RunDaemon(ctx context.Context, cfg config.Config) error {
-	w, err := local.NewWatcher(cfg.LocalDir)
+type daemon struct {
+	cfg    config.Config
+	db     *db.Database
+	drv    gdrive.Remote
+	rootID string
+	stop   context.CancelCauseFunc
+	// sharedDrives maps the IDs of the synced shared drives onto their directory relative to the local directory
+	sharedDrives map[string]string
+	selection    selection
+	ignorer      *local.Ignorer
+	// deletions are the recent trashings of locally removed files, which count towards the deletion limits together
+	deletions []deletion
+
+	// mu serializes the application of remote changes and the handling of local events
+	mu sync.Mutex
+	// folders serializes the creation of remote folders by concurrent uploads
+	folders sync.Mutex
+}
+
+func RunDaemon(ctx context.Context, cfg config.Config, drv gdrive.Remote) error {
+	if len(cfg.SyncPairs) > 0 {
+		return runPairs(ctx, cfg, drv)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return fmt.Errorf("run-daemon: could not create database: %w", err)
+	}
+	defer d.Close()
+
+	isPaused, err := d.Queries().IsPaused(ctx)
+	if err != nil {
+		return fmt.Errorf("run-daemon: could not check if sync is paused: %w", err)
+	}
+	if isPaused {
+		return fmt.Errorf("run-daemon: %w", errSyncPaused)
+	}
+
+	dmn, err := newDaemon(ctx, cfg, d, drv)
+	if err != nil {
+		return fmt.Errorf("run-daemon: %w", err)
+	}
+	ctx, dmn.stop = context.WithCancelCause(ctx)
+	defer dmn.stop(nil)
+
+	w, err := local.NewWatcher(cfg.LocalDir, dmn.ignorer)
 	if err != nil {
 		return fmt.Errorf("run-daemon: could not create watcher: %w", err)
 	}
 	defer w.Close()
 
-	go consumeWatcherEvents(w.Events)
+	go dmn.consumeWatcherEvents(ctx, w.Events)
+	go func() {
+		// Local changes made while the daemon was not running take precedence over remote changes
+		dmn.scanLocal(ctx)
+		dmn.pollRemoteChanges(ctx)
+	}()
 	err = w.Run(ctx)
 	if err != nil {
 		return fmt.Errorf("run-daemon: error while running watcher: %w", err)
 	}
+	if cause := context.Cause(ctx); errors.Is(cause, errSyncPaused) {
+		return fmt.Errorf("run-daemon: %w", cause)
+	}
 	return nil
 }
 
-func consumeWatcherEvents(c <-chan fsnotify.Event) {
-	for event := range c {
-		switch {
-		case event.Has(fsnotify.Create):
-			logging.Debugf("Received create event: %s", event)
-		case event.Has(fsnotify.Write):
-			logging.Debugf("Received write event: %s", event)
-		case event.Has(fsnotify.Remove):
-			logging.Debugf("Received remove event: %s", event)
+func newDaemon(
+	ctx context.Context,
+	cfg config.Config,
+	d *db.Database,
+	drv gdrive.Remote,
+) (*daemon, error) {
+	root, err := rootFolder(ctx, drv, cfg)
+	if err != nil {
+		return nil, err
+	}
+	drives, err := d.Queries().GetSharedDrives(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get shared drives: %w", err)
+	}
+	sharedDrives := make(map[string]string, len(drives))
+	roots := map[string]string{root.Id: ""}
+	for _, sd := range drives {
+		sharedDrives[sd.DriveID] = sd.LocalDir
+		roots[sd.DriveID] = sd.LocalDir
+	}
+	sel, err := newSelection(ctx, drv, cfg, roots)
+	if err != nil {
+		return nil, err
+	}
+	return &daemon{
+		cfg:          cfg,
+		db:           d,
+		drv:          drv,
+		rootID:       root.Id,
+		sharedDrives: sharedDrives,
+		selection:    sel,
+		ignorer:      local.NewIgnorer(cfg.LocalDir),
+	}, nil
+}
+
+func (d *daemon) pollRemoteChanges(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.syncRemoteChanges(ctx); err != nil {
+			logging.Errorf("Could not apply remote changes: %s", err)
+		}
+		if err := d.retryFailedDownloads(ctx); err != nil {
+			logging.Errorf("Could not retry failed downloads: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
 }
+
+// scanLocal syncs all local files modified since they were last synced and all tracked files that no longer exist.
+func (d *daemon) scanLocal(ctx context.Context) {
+	changed := newLocalChanges()
+
+	files, err := d.db.Queries().GetAllFiles(ctx)
+	if err != nil {
+		logging.Errorf("Could not scan local directory: %s", err)
+		return
+	}
+	lastModified := make(map[string]int64, len(files))
+	for _, f := range files {
+		lastModified[f.Path] = f.LastModified
+		if d.ignorer.Ignored(f.Path, f.MimeType == FolderMimeType) {
+			continue
+		}
+		if _, err = os.Lstat(filepath.Join(d.cfg.LocalDir, f.Path)); errors.Is(err, os.ErrNotExist) {
+			changed.ops[filepath.Join(d.cfg.LocalDir, f.Path)] = fsnotify.Remove
+		}
+	}
+
+	err = filepath.WalkDir(
+		d.cfg.LocalDir, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(d.cfg.LocalDir, path)
+			if err != nil || rel == "." || !e.Type().IsRegular() && !e.IsDir() {
+				return err
+			}
+			if !d.selection.includes(rel, e.IsDir()) || d.ignorer.Ignored(rel, e.IsDir()) {
+				return skipEntry(e)
+			}
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			if synced, ok := lastModified[rel]; !ok || info.ModTime().Unix() > synced && !e.IsDir() {
+				changed.ops[path] = fsnotify.Create
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		logging.Errorf("Could not scan local directory: %s", err)
+		return
+	}
+
+	logging.Debugf("Found %d local changes while scanning", len(changed.ops))
+	d.syncLocalChanges(ctx, changed)
+}
@@ -0,0 +1,518 @@
+package service
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/googleapi"
+)
+
+// newTestDaemon performs the initial sync of fake as configured by cfg and returns a daemon syncing changes made
+// afterwards.
+func newTestDaemon(t *testing.T, cfg config.Config, fake *gdrive.Fake) *daemon {
+	t.Helper()
+	ctx := context.Background()
+	d := testDatabase(t, cfg)
+	if _, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil); err != nil {
+		t.Fatalf("performInitialSync() = %v", err)
+	}
+	pageToken, err := fake.StartPageToken(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = persistPageToken(ctx, d.Queries(), pageToken); err != nil {
+		t.Fatal(err)
+	}
+	dmn, err := newDaemon(ctx, cfg, d, fake)
+	if err != nil {
+		t.Fatalf("newDaemon() = %v", err)
+	}
+	_, dmn.stop = context.WithCancelCause(ctx)
+	return dmn
+}
+
+// localFiles returns the content of all regular files below dir except partial downloads by their slash-separated
+// path relative to dir.
+func localFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(
+		dir, func(p string, e fs.DirEntry, err error) error {
+			if err != nil || !e.Type().IsRegular() || strings.HasSuffix(p, partialSuffix) {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			b, err := os.ReadFile(p)
+			files[filepath.ToSlash(rel)] = string(b)
+			return err
+		},
+	)
+	if err != nil {
+		t.Fatalf("could not read local files: %s", err)
+	}
+	return files
+}
+
+// remoteFiles returns the content of all files of My Drive of fake that are not trashed by their path.
+func remoteFiles(t *testing.T, fake *gdrive.Fake) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	var walk func(id, dir string)
+	walk = func(id, dir string) {
+		children, err := fake.ListChildren(context.Background(), id, "", "")
+		if err != nil {
+			t.Fatalf("could not list remote files: %s", err)
+		}
+		for _, f := range children {
+			if f.MimeType == FolderMimeType {
+				walk(f.Id, path.Join(dir, f.Name))
+				continue
+			}
+			content, _ := fake.Content(f.Id)
+			files[path.Join(dir, f.Name)] = string(content)
+		}
+	}
+	walk(fake.RootID(), "")
+	return files
+}
+
+func assertFiles(t *testing.T, kind string, got, want map[string]string) {
+	t.Helper()
+	if !maps.Equal(got, want) {
+		t.Errorf("%s files = %v, want %v", kind, got, want)
+	}
+}
+
+// testTree adds the files the tests of the daemon start with to fake and returns their IDs by path.
+func testTree(fake *gdrive.Fake) map[string]string {
+	folder := fake.AddFolder(fake.RootID(), "A")
+	return map[string]string{
+		"A":       folder,
+		"A/a.txt": fake.AddFile(folder, "a.txt", []byte("a")),
+		"b.txt":   fake.AddFile(fake.RootID(), "b.txt", []byte("b")),
+	}
+}
+
+func TestSyncRemoteChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, fake *gdrive.Fake, ids map[string]string)
+		want   map[string]string
+	}{
+		{
+			name: "add",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				fake.AddFile(ids["A"], "c.txt", []byte("c"))
+			},
+			want: map[string]string{"A/a.txt": "a", "A/c.txt": "c", "b.txt": "b"},
+		},
+		{
+			name: "add folder",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				fake.AddFile(fake.AddFolder(ids["A"], "C"), "c.txt", []byte("c"))
+			},
+			want: map[string]string{"A/a.txt": "a", "A/C/c.txt": "c", "b.txt": "b"},
+		},
+		{
+			name: "modify",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.SetContent(ids["b.txt"], []byte("b2")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"A/a.txt": "a", "b.txt": "b2"},
+		},
+		{
+			name: "trash",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Trash(context.Background(), ids["b.txt"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"A/a.txt": "a"},
+		},
+		{
+			name: "trash folder",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Trash(context.Background(), ids["A"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"b.txt": "b"},
+		},
+		{
+			name: "delete",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Delete(ids["A/a.txt"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"b.txt": "b"},
+		},
+		{
+			name: "move",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Move(context.Background(), ids["b.txt"], "b2.txt", ids["A"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"A/a.txt": "a", "A/b2.txt": "b"},
+		},
+		{
+			name: "move folder",
+			change: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Move(context.Background(), ids["A"], "Z", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"Z/a.txt": "a", "b.txt": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := gdrive.NewFake()
+			ids := testTree(fake)
+			cfg := testConfig(t)
+			dmn := newTestDaemon(t, cfg, fake)
+
+			tt.change(t, fake, ids)
+			if err := dmn.syncRemoteChanges(context.Background()); err != nil {
+				t.Fatalf("syncRemoteChanges() = %v", err)
+			}
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), tt.want)
+		})
+	}
+}
+
+// localChange describes a change of the local directory and the events the watcher reports for it.
+type localChange struct {
+	// remove, write and rename are paths relative to the local directory, rename maps new paths onto old ones
+	remove []string
+	write  map[string]string
+	rename map[string]string
+	// events reports whether the watcher saw renames as such, they are only seen as removal and creation otherwise
+	events bool
+}
+
+// apply makes the change in dir and returns the local changes reported for it.
+func (c localChange) apply(t *testing.T, dir string) localChanges {
+	t.Helper()
+	changes := newLocalChanges()
+	abs := func(p string) string { return filepath.Join(dir, filepath.FromSlash(p)) }
+	for _, p := range c.remove {
+		if err := os.RemoveAll(abs(p)); err != nil {
+			t.Fatal(err)
+		}
+		changes.ops[abs(p)] |= fsnotify.Remove
+	}
+	for _, p := range slices.Sorted(maps.Keys(c.write)) {
+		if err := os.MkdirAll(filepath.Dir(abs(p)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs(p), []byte(c.write[p]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		changes.ops[abs(p)] |= fsnotify.Write
+	}
+	for newPath, oldPath := range c.rename {
+		if err := os.MkdirAll(filepath.Dir(abs(newPath)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(abs(oldPath), abs(newPath)); err != nil {
+			t.Fatal(err)
+		}
+		changes.ops[abs(oldPath)] |= fsnotify.Rename
+		changes.ops[abs(newPath)] |= fsnotify.Create
+		if c.events {
+			changes.renames[abs(newPath)] = abs(oldPath)
+		}
+	}
+	return changes
+}
+
+func TestSyncLocalChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change localChange
+		want   map[string]string
+		// moved is the path of b.txt on Drive after the change if it was moved, keeping its ID
+		moved string
+	}{
+		{
+			name:   "create",
+			change: localChange{write: map[string]string{"A/c.txt": "c"}},
+			want:   map[string]string{"A/a.txt": "a", "A/c.txt": "c", "b.txt": "b"},
+		},
+		{
+			name:   "create in new folder",
+			change: localChange{write: map[string]string{"C/D/c.txt": "c"}},
+			want:   map[string]string{"A/a.txt": "a", "C/D/c.txt": "c", "b.txt": "b"},
+		},
+		{
+			name:   "modify",
+			change: localChange{write: map[string]string{"b.txt": "b2"}},
+			want:   map[string]string{"A/a.txt": "a", "b.txt": "b2"},
+		},
+		{
+			name:   "rename",
+			change: localChange{rename: map[string]string{"A/b2.txt": "b.txt"}, events: true},
+			want:   map[string]string{"A/a.txt": "a", "A/b2.txt": "b"},
+			moved:  "A/b2.txt",
+		},
+		{
+			name:   "rename detected by content",
+			change: localChange{rename: map[string]string{"A/b2.txt": "b.txt"}},
+			want:   map[string]string{"A/a.txt": "a", "A/b2.txt": "b"},
+			moved:  "A/b2.txt",
+		},
+		{
+			name:   "delete",
+			change: localChange{remove: []string{"b.txt"}},
+			want:   map[string]string{"A/a.txt": "a"},
+		},
+		{
+			name:   "delete folder",
+			change: localChange{remove: []string{"A"}},
+			want:   map[string]string{"b.txt": "b"},
+		},
+		{
+			name:   "ignored",
+			change: localChange{write: map[string]string{"c.tmp": "c", ".git/config": "c"}},
+			want:   map[string]string{"A/a.txt": "a", "b.txt": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := gdrive.NewFake()
+			ids := testTree(fake)
+			cfg := testConfig(t)
+			dmn := newTestDaemon(t, cfg, fake)
+
+			dmn.syncLocalChanges(context.Background(), tt.change.apply(t, cfg.LocalDir))
+			assertFiles(t, "remote", remoteFiles(t, fake), tt.want)
+			if tt.moved == "" {
+				return
+			}
+			tracked, err := dmn.db.Queries().GetFile(context.Background(), tt.moved)
+			if err != nil || tracked.DriveID != ids["b.txt"] {
+				t.Errorf("%s is tracked as %s, %v, want %s", tt.moved, tracked.DriveID, err, ids["b.txt"])
+			}
+		})
+	}
+}
+
+func TestTrashRemovedPausesAboveDeletionLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxDeletions int
+		maxPercent   int
+		remove       []string
+		paused       bool
+		want         map[string]string
+	}{
+		{
+			name:         "below limits",
+			maxDeletions: 1,
+			maxPercent:   50,
+			remove:       []string{"b.txt"},
+			want:         map[string]string{"A/a.txt": "a"},
+		},
+		{
+			name:         "above deletion limit",
+			maxDeletions: 1,
+			remove:       []string{"A", "b.txt"},
+			paused:       true,
+			want:         map[string]string{"A/a.txt": "a", "b.txt": "b"},
+		},
+		{
+			name:       "above percent limit",
+			maxPercent: 50,
+			remove:     []string{"A", "b.txt"},
+			paused:     true,
+			want:       map[string]string{"A/a.txt": "a", "b.txt": "b"},
+		},
+		{
+			name:   "limits disabled",
+			remove: []string{"A", "b.txt"},
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			testTree(fake)
+			cfg := testConfig(t)
+			cfg.MaxDeletions, cfg.MaxDeletionPercent = tt.maxDeletions, tt.maxPercent
+			dmn := newTestDaemon(t, cfg, fake)
+
+			dmn.syncLocalChanges(ctx, localChange{remove: tt.remove}.apply(t, cfg.LocalDir))
+			assertFiles(t, "remote", remoteFiles(t, fake), tt.want)
+			paused, err := dmn.db.Queries().IsPaused(ctx)
+			if err != nil || paused != tt.paused {
+				t.Errorf("IsPaused() = %t, %v, want %t", paused, err, tt.paused)
+			}
+		})
+	}
+}
+
+func TestTrashRemovedCountsDeletionsAcrossBatches(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxDeletions int
+		maxPercent   int
+		// elapsed is the time between the batches
+		elapsed time.Duration
+		paused  bool
+		want    map[string]string
+	}{
+		{
+			name:         "above deletion limit",
+			maxDeletions: 1,
+			paused:       true,
+			want:         map[string]string{"A/a.txt": "a", "c.txt": "c", "d.txt": "d"},
+		},
+		{
+			name:       "above percent limit",
+			maxPercent: 40,
+			paused:     true,
+			want:       map[string]string{"A/a.txt": "a", "c.txt": "c", "d.txt": "d"},
+		},
+		{
+			name:         "after the deletion window",
+			maxDeletions: 1,
+			elapsed:      deletionWindow + time.Second,
+			want:         map[string]string{"c.txt": "c", "d.txt": "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			testTree(fake)
+			fake.AddFile(fake.RootID(), "c.txt", []byte("c"))
+			fake.AddFile(fake.RootID(), "d.txt", []byte("d"))
+			cfg := testConfig(t)
+			cfg.MaxDeletions, cfg.MaxDeletionPercent = tt.maxDeletions, tt.maxPercent
+			dmn := newTestDaemon(t, cfg, fake)
+
+			// Each batch is within the limits on its own
+			dmn.syncLocalChanges(ctx, localChange{remove: []string{"b.txt"}}.apply(t, cfg.LocalDir))
+			for i := range dmn.deletions {
+				dmn.deletions[i].at = dmn.deletions[i].at.Add(-tt.elapsed)
+			}
+			dmn.syncLocalChanges(ctx, localChange{remove: []string{"A"}}.apply(t, cfg.LocalDir))
+
+			assertFiles(t, "remote", remoteFiles(t, fake), tt.want)
+			paused, err := dmn.db.Queries().IsPaused(ctx)
+			if err != nil || paused != tt.paused {
+				t.Errorf("IsPaused() = %t, %v, want %t", paused, err, tt.paused)
+			}
+		})
+	}
+}
+
+func TestSyncRemoteChangesDownloadErrors(t *testing.T) {
+	forbidden := &googleapi.Error{Code: http.StatusForbidden}
+	tests := []struct {
+		name string
+		// errs are returned by consecutive downloads, a nil error lets the download succeed
+		errs []error
+		// failed are the downloads expected to be recorded as failed by the first sync
+		failed []string
+	}{
+		{name: "no error"},
+		{name: "transient error is retried", errs: []error{io.ErrUnexpectedEOF}},
+		{name: "permanent error is recorded", errs: []error{forbidden}, failed: []string{"A/a.txt"}},
+		{name: "permanent error mid-page is recorded", errs: []error{nil, forbidden}, failed: []string{"b.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			ids := testTree(fake)
+			cfg := testConfig(t)
+			dmn := newTestDaemon(t, cfg, fake)
+
+			synced := map[string]string{"A/a.txt": "a2", "b.txt": "b2"}
+			// Both changes are listed on the same page, in this order
+			for _, p := range []string{"A/a.txt", "b.txt"} {
+				if err := fake.SetContent(ids[p], []byte(synced[p])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, err := range tt.errs {
+				fake.InjectError("Download", err)
+			}
+			if err := dmn.syncRemoteChanges(ctx); err != nil {
+				t.Fatalf("syncRemoteChanges() = %v", err)
+			}
+			if got := failedDownloadPaths(t, dmn.db); !slices.Equal(got, tt.failed) {
+				t.Fatalf("failed downloads = %v, want %v", got, tt.failed)
+			}
+			want := maps.Clone(synced)
+			for _, p := range tt.failed {
+				want[p] = strings.TrimSuffix(path.Base(p), ".txt")
+			}
+			// The page token was advanced, so syncing again neither downloads the failed file again nor mistakes it
+			// for a conflict
+			if err := dmn.syncRemoteChanges(ctx); err != nil {
+				t.Fatalf("syncRemoteChanges() = %v", err)
+			}
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), want)
+
+			for _, p := range tt.failed {
+				// Make the failed download due for a retry
+				err := dmn.db.Queries().UpsertFailedDownload(
+					ctx, sqlc.UpsertFailedDownloadParams{DriveID: ids[p], Path: p},
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := dmn.retryFailedDownloads(ctx); err != nil {
+				t.Fatalf("retryFailedDownloads() = %v", err)
+			}
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), synced)
+			assertFiles(t, "remote", remoteFiles(t, fake), synced)
+			if got := failedDownloadPaths(t, dmn.db); len(got) > 0 {
+				t.Errorf("failed downloads = %v, want none", got)
+			}
+			if conflicts, err := dmn.db.Queries().GetConflicts(ctx); err != nil || len(conflicts) > 0 {
+				t.Errorf("GetConflicts() = %v, %v, want none", conflicts, err)
+			}
+		})
+	}
+}
+
+// failedDownloadPaths returns the paths of the failed downloads recorded in d.
+func failedDownloadPaths(t *testing.T, d *db.Database) []string {
+	t.Helper()
+	failed, err := d.Queries().GetFailedDownloads(context.Background())
+	if err != nil {
+		t.Fatalf("could not get failed downloads: %s", err)
+	}
+	var paths []string
+	for _, fd := range failed {
+		paths = append(paths, fd.Path)
+	}
+	return paths
+}
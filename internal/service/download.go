@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	// partialSuffix is appended to the path of a file while it is downloaded. Partial files are ignored by sync.
+	partialSuffix = ".park-partial"
+
+	checksumFields = "md5Checksum, sha1Checksum, sha256Checksum, size"
+)
+
+// errChecksumMismatch is returned if downloaded content does not match the checksums provided by Drive, which is
+// retried as the content was likely corrupted in transit.
+var errChecksumMismatch = errors.New("content does not match the checksum provided by Drive")
+
+// downloadContent writes the content of f to absoluteLocalPath and returns its SHA3-256 hash. The content is first
+// downloaded to a partial file next to absoluteLocalPath, which a later call resumes from if the download fails. Once
+// complete, it is verified against the checksums provided by Drive and renamed into place.
+func downloadContent(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	f *drive.File,
+	absoluteLocalPath string,
+) ([]byte, error) {
+	partialPath := absoluteLocalPath + partialSuffix
+	if err := resumeDownload(ctx, drv, cfg, f, partialPath); err != nil {
+		return nil, fmt.Errorf("could not download file '%s': %w", f.Name, err)
+	}
+
+	sum, err := verifyChecksums(f, partialPath)
+	if err != nil {
+		// Resuming would keep the corrupted content, the next download starts over
+		_ = os.Remove(partialPath)
+		return nil, fmt.Errorf("could not verify file '%s': %w", f.Name, err)
+	}
+	if err = os.Rename(partialPath, absoluteLocalPath); err != nil {
+		return nil, fmt.Errorf("could not move file '%s' into place: %w", absoluteLocalPath, err)
+	}
+	return sum, nil
+}
+
+// resumeDownload appends the content of f that is missing from the partial file at partialPath to it, using a range
+// request if part of the content was already downloaded.
+func resumeDownload(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	f *drive.File,
+	partialPath string,
+) error {
+	out, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open partial file: %w", err)
+	}
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("could not seek partial file: %w", err)
+	}
+
+	if offset > 0 {
+		logging.Debugf("Resuming download of %s at byte %d", f.Name, offset)
+	}
+	res, err := drv.Download(ctx, f.Id, offset)
+	if isRangeNotSatisfiable(err) && offset == f.Size {
+		// The partial file is complete
+		return nil
+	}
+	if isRangeNotSatisfiable(err) {
+		// The partial file is larger than the file, it belongs to another version and is downloaded again
+		if err = out.Truncate(0); err != nil {
+			return fmt.Errorf("could not truncate partial file: %w", err)
+		}
+		return resumeDownload(ctx, drv, cfg, f, partialPath)
+	}
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			logging.Debugf("Could not close body: %s", err)
+		}
+	}(res.Body)
+
+	if offset > 0 && res.StatusCode != http.StatusPartialContent {
+		// The range was ignored and the whole content is sent
+		if err = out.Truncate(0); err != nil {
+			return fmt.Errorf("could not truncate partial file: %w", err)
+		}
+		if _, err = out.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("could not seek partial file: %w", err)
+		}
+	}
+	if _, err = io.Copy(out, throttleDownload(cfg, res.Body)); err != nil {
+		return fmt.Errorf("could not write partial file: %w", err)
+	}
+	return out.Close()
+}
+
+// verifyChecksums compares the content of the file at path with the checksums Drive provides for f and returns its
+// SHA3-256 hash.
+func verifyChecksums(f *drive.File, path string) ([]byte, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	sha3Hash, md5Hash, sha1Hash, sha256Hash := crypto.SHA3_256.New(), md5.New(), sha1.New(), sha256.New()
+	if _, err = io.Copy(io.MultiWriter(sha3Hash, md5Hash, sha1Hash, sha256Hash), in); err != nil {
+		return nil, err
+	}
+	for _, c := range []struct {
+		expected string
+		actual   hash.Hash
+	}{
+		{f.Md5Checksum, md5Hash},
+		{f.Sha1Checksum, sha1Hash},
+		{f.Sha256Checksum, sha256Hash},
+	} {
+		if c.expected != "" && c.expected != hex.EncodeToString(c.actual.Sum(nil)) {
+			return nil, errChecksumMismatch
+		}
+	}
+	return sha3Hash.Sum(nil), nil
+}
+
+// sameRemoteContent reports whether the content of remote is the content known was synced with, according to the
+// MD5 checksum Drive provides for it. Google documents have no checksum and are never considered the same.
+func sameRemoteContent(known sqlc.File, remote *drive.File) bool {
+	return known.RemoteMd5 != "" && known.RemoteMd5 == remote.Md5Checksum
+}
+
+// writeContent writes the body of res to absoluteLocalPath, limited to the configured download rate, and returns its
+// SHA3-256 hash. The body is written to a partial file first, so a failure never leaves a truncated file behind.
+func writeContent(cfg config.Config, res *http.Response, absoluteLocalPath string) ([]byte, error) {
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			logging.Debugf("Could not close body: %s", err)
+		}
+	}(res.Body)
+
+	partialPath := absoluteLocalPath + partialSuffix
+	out, err := os.Create(partialPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create file '%s': %w", partialPath, err)
+	}
+
+	sha := crypto.SHA3_256.New()
+	_, err = io.Copy(io.MultiWriter(out, sha), throttleDownload(cfg, res.Body))
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(partialPath)
+		return nil, fmt.Errorf("could not write file '%s': %w", absoluteLocalPath, err)
+	}
+	if err = out.Close(); err != nil {
+		return nil, fmt.Errorf("could not close file '%s': %w", partialPath, err)
+	}
+	if err = os.Rename(partialPath, absoluteLocalPath); err != nil {
+		return nil, fmt.Errorf("could not move file '%s' into place: %w", absoluteLocalPath, err)
+	}
+	return sha.Sum(nil), nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/drive/v3"
+)
+
+// exportExtension returns the file extension Google-native documents of mimeType are exported with, or an empty
+// string if they are not exported.
+func exportExtension(cfg config.Config, mimeType string) string {
+	format, _, _ := cfg.ExportFormat(mimeType)
+	if format == config.LinkFormat {
+		return linkExtensions[strings.TrimPrefix(mimeType, GoogleAppsMimeTypePrefix)]
+	}
+	return format
+}
+
+// isUnexported reports whether documents of mimeType are Google-native documents without an export format, which
+// have no content that could be synced.
+func isUnexported(cfg config.Config, mimeType string) bool {
+	return strings.HasPrefix(mimeType, GoogleAppsMimeTypePrefix) && exportExtension(cfg, mimeType) == ""
+}
+
+// fetchContent writes the content of f to absoluteLocalPath and returns its SHA3-256 hash. Google-native documents
+// are exported in the configured format or materialized as link files.
+func fetchContent(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	f *drive.File,
+	absoluteLocalPath string,
+) ([]byte, error) {
+	format, exportMimeType, ok := cfg.ExportFormat(f.MimeType)
+	if !ok {
+		return downloadContent(ctx, drv, cfg, f, absoluteLocalPath)
+	}
+	if format == config.LinkFormat || format == config.DesktopLinkFormat {
+		return writeLinkFile(f, format, absoluteLocalPath)
+	}
+	res, err := drv.Export(ctx, f.Id, exportMimeType)
+	if err != nil {
+		return nil, fmt.Errorf("could not export file '%s': %w", f.Name, err)
+	}
+	return writeContent(cfg, res, absoluteLocalPath)
+}
@@ -2,19 +2,27 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/torfstack/park/internal/config"
 	"github.com/torfstack/park/internal/db"
 	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
 	"github.com/torfstack/park/internal/logging"
-	"github.com/torfstack/park/internal/util"
-	"google.golang.org/api/drive/v3"
 )
 
-func InitialSync(ctx context.Context, cfg config.Config, drv *drive.Service) error {
-	d, err := db.New(ctx)
+// InitialSync downloads all selected files to the local directory. Its progress is persisted per file, so running it
+// again after it was interrupted resumes where it stopped and skips the files that were already downloaded. The
+// state is only marked as initialized once every file was either downloaded or recorded as a failed download, which
+// the daemon retries later. If cfg has sync pairs, each of them is synced instead of My Drive.
+func InitialSync(ctx context.Context, cfg config.Config, drv gdrive.Remote) error {
+	if len(cfg.SyncPairs) > 0 {
+		return initialSyncPairs(ctx, cfg, drv)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
 	if err != nil {
 		return fmt.Errorf("could not create database: %w", err)
 	}
@@ -29,39 +37,24 @@ func InitialSync(ctx context.Context, cfg config.Config, drv *drive.Service) err
 		return nil
 	}
 
-	pageToken, err := initialPageToken(drv)
+	sharedDrives, err := prepareInitialSync(ctx, d, cfg, drv)
 	if err != nil {
-		return fmt.Errorf("could not get initial page token: %w", err)
+		return err
 	}
 
-	tempDir, err := util.CreateTempDir()
+	numFailed, err := performInitialSync(ctx, d, cfg, drv, cfg.LocalDir, sharedDrives)
 	if err != nil {
-		return fmt.Errorf("could not create temp dir: %w", err)
+		return fmt.Errorf("could not perform initial sync, run `park init` again to resume: %w", err)
 	}
-	defer os.RemoveAll(tempDir)
 
 	err = d.WithTransaction(
 		ctx, func(q *sqlc.Queries) error {
-			err = performInitialSync(ctx, q, drv, tempDir)
-			if err != nil {
-				return fmt.Errorf("could not perform initial sync: %w", err)
+			if err := q.ClearInitialSync(ctx); err != nil {
+				return fmt.Errorf("could not clear initial sync progress: %w", err)
 			}
-
-			err = os.Rename(tempDir, cfg.LocalDir)
-			if err != nil {
-				return fmt.Errorf("could not rename temp dir: %w", err)
-			}
-
-			err = persistPageToken(ctx, q, pageToken)
-			if err != nil {
-				return fmt.Errorf("could not persist page token: %w", err)
-			}
-
-			err = q.SetInitialized(ctx)
-			if err != nil {
+			if err := q.SetInitialized(ctx); err != nil {
 				return fmt.Errorf("could not set initialized flag: %w", err)
 			}
-
 			return nil
 		},
 	)
@@ -69,18 +62,119 @@ func InitialSync(ctx context.Context, cfg config.Config, drv *drive.Service) err
 		return fmt.Errorf("transaction failed: %w", err)
 	}
 
+	if numFailed > 0 {
+		return reportFailedDownloads(ctx, d.Queries())
+	}
+	logging.Info("Initial sync finished!")
 	return nil
 }
 
-func initialPageToken(drv *drive.Service) (string, error) {
-	initialToken, err := drv.Changes.GetStartPageToken().Do()
+// reportFailedDownloads logs the files that could not be downloaded and returns an error summarizing them.
+func reportFailedDownloads(ctx context.Context, q *sqlc.Queries) error {
+	failed, err := q.GetFailedDownloads(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get failed downloads: %w", err)
+	}
+	logging.Errorf("Initial sync finished, but %d files could not be downloaded:", len(failed))
+	for _, f := range failed {
+		logging.Errorf("  %s: %s", f.Path, f.Error)
+	}
+	return fmt.Errorf("%d files could not be downloaded, `park daemon` retries them", len(failed))
+}
+
+// prepareInitialSync returns the shared drives to sync. When the initial sync starts, they are chosen by the user and
+// persisted together with the page tokens from which changes are applied once the initial sync finished. When it is
+// resumed, the persisted choice is returned.
+func prepareInitialSync(
+	ctx context.Context,
+	d *db.Database,
+	cfg config.Config,
+	drv gdrive.Remote,
+) ([]config.SharedDrive, error) {
+	pageToken, err := d.Queries().GetPageToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get page token: %w", err)
+	}
+	if pageToken != "" {
+		logging.Info("Resuming initial sync")
+		drives, err := d.Queries().GetSharedDrives(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get shared drives: %w", err)
+		}
+		sharedDrives := make([]config.SharedDrive, len(drives))
+		for i, sd := range drives {
+			sharedDrives[i] = config.SharedDrive{ID: sd.DriveID, Name: sd.Name, LocalDir: sd.LocalDir}
+		}
+		return sharedDrives, nil
+	}
+
+	entries, err := os.ReadDir(cfg.LocalDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read local directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("local directory '%s' is not empty", cfg.LocalDir)
+	}
+
+	var sharedDrives []config.SharedDrive
+	// Shared drives are synced next to My Drive, not into sync pairs
+	if cfg.RootFolder == "" {
+		available, err := listSharedDrives(ctx, drv)
+		if err != nil {
+			return nil, err
+		}
+		sharedDrives, err = config.ChooseSharedDrives(available)
+		if err != nil {
+			return nil, fmt.Errorf("could not choose shared drives: %w", err)
+		}
+	}
+
+	// Page tokens are taken before walking, so changes made while the initial sync runs are not lost
+	pageToken, err = initialPageToken(ctx, drv, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not get initial page token: %w", err)
+	}
+	sharedDrivePageTokens := make([]string, len(sharedDrives))
+	for i, sd := range sharedDrives {
+		sharedDrivePageTokens[i], err = initialPageToken(ctx, drv, sd.ID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get initial page token of shared drive '%s': %w", sd.Name, err)
+		}
+	}
+
+	err = d.WithTransaction(
+		ctx, func(q *sqlc.Queries) error {
+			for i, sd := range sharedDrives {
+				err := q.UpsertSharedDrive(ctx, sqlc.UpsertSharedDriveParams{
+					DriveID:   sd.ID,
+					Name:      sd.Name,
+					LocalDir:  sd.LocalDir,
+					PageToken: sharedDrivePageTokens[i],
+				})
+				if err != nil {
+					return fmt.Errorf("could not persist shared drive '%s': %w", sd.Name, err)
+				}
+			}
+			return persistPageToken(ctx, q, pageToken)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("transaction failed: %w", err)
+	}
+	return sharedDrives, nil
+}
+
+// initialPageToken returns the current page token of the shared drive with the given ID, or of My Drive if driveID
+// is empty.
+func initialPageToken(ctx context.Context, drv gdrive.Remote, driveID string) (string, error) {
+	initialToken, err := drv.StartPageToken(ctx, driveID)
 	if err != nil {
 		return "", fmt.Errorf("initialPageToken; could not get initial page token: %w", err)
 	}
-	if initialToken.StartPageToken == "" {
+	if initialToken == "" {
 		return "", fmt.Errorf("initialPageToken; empty page token")
 	}
-	return initialToken.StartPageToken, nil
+	return initialToken, nil
 }
 
 func persistPageToken(ctx context.Context, q *sqlc.Queries, pageToken string) error {
@@ -1,18 +1,23 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"crypto"
+	"database/sql"
+	"errors"
 	"fmt"
-	"io"
 	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
 	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
 	"github.com/torfstack/park/internal/logging"
 	"google.golang.org/api/drive/v3"
 )
@@ -22,7 +27,8 @@ const (
 	ShortcutMimeType = "application/vnd.google-apps.shortcut"
 	RootFolderId     = "root"
 
-	NumWorkers = 4
+	walkFields = "id, name, mimeType, parents, headRevisionId, version, webViewLink, shortcutDetails, driveId, " +
+		checksumFields
 )
 
 type job struct {
@@ -30,50 +36,98 @@ type job struct {
 	syncCtx *syncContext
 }
 
+type downloadResult struct {
+	file     *drive.File
+	parkFile *parkFile
+	err      error
+}
+
 type parkFile struct {
-	Path        string
-	FileId      string
-	ContentHash []byte
+	Path string
+	// FileId is the key the file was tracked by while walking, see syncContext.key
+	FileId string
+	// DriveID is the ID of the Drive file of the content, ShortcutID the ID of the followed shortcut it was synced
+	// through, if any
+	DriveID       string
+	ShortcutID    string
+	ContentHash   []byte
+	MimeType      string
+	HeadRevision  string
+	RemoteVersion int64
+	ExportFormat  string
+	RemoteMd5     string
 }
 
-func performInitialSync(ctx context.Context, q *sqlc.Queries, drv *drive.Service, intoDir string) error {
-	syncCtx := syncContext{
-		fileMap: make(map[string]*drive.File),
-		parents: make(map[string]string),
-	}
+const (
+	initialSyncPending = "pending"
+	initialSyncDone    = "done"
+)
 
-	err := walkFolder(ctx, drv, RootFolderId, intoDir, &syncCtx)
+// performInitialSync downloads the files selected for sync to intoDir. Files that were already downloaded by an
+// interrupted previous run and whose local copy is unchanged are skipped. Each downloaded file is persisted right away,
+// so the progress survives another interruption. Downloads failing with transient errors are retried, files that
+// still cannot be downloaded are recorded as failed downloads and their number is returned.
+func performInitialSync(
+	ctx context.Context,
+	d *db.Database,
+	cfg config.Config,
+	drv gdrive.Remote,
+	intoDir string,
+	sharedDrives []config.SharedDrive,
+) (int, error) {
+	syncCtx, err := collectFiles(ctx, cfg, drv, intoDir, sharedDrives)
 	if err != nil {
-		return fmt.Errorf("error walking root folder: %w", err)
+		return 0, err
 	}
 
-	err = createDirs(intoDir, &syncCtx)
+	err = createDirs(intoDir, syncCtx)
 	if err != nil {
-		return fmt.Errorf("error creating initial directories: %w", err)
+		return 0, fmt.Errorf("error creating initial directories: %w", err)
 	}
 	logging.Debug("Created initial directories")
 
+	err = persistDirs(ctx, d.Queries(), syncCtx)
+	if err != nil {
+		return 0, fmt.Errorf("error persisting initial directories: %w", err)
+	}
+
+	var pending []*drive.File
+	err = d.WithTransaction(
+		ctx, func(q *sqlc.Queries) error {
+			pending, err = pendingDownloads(ctx, q, cfg, intoDir, syncCtx)
+			return err
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("could not determine pending downloads: %w", err)
+	}
+	logging.Infof("Downloading %d files", len(pending))
+
 	jobs := make(chan job)
-	results := make(chan parkFile)
+	results := make(chan downloadResult)
 	var wg sync.WaitGroup
 
 	logging.Debug("Starting download workers")
-	for i := 0; i < NumWorkers; i++ {
+	for i := 0; i < max(cfg.DownloadWorkers, 1); i++ {
 		wg.Go(
 			func() {
 				for j := range jobs {
-					parkFile, errGo := downloadFile(drv, intoDir, j.file, &syncCtx)
-					if errGo != nil {
-						logging.Debugf("Skipping: could not download file '%s': %s", j.file.Name, errGo)
-						continue
-					}
-					results <- *parkFile
+					var parkFile *parkFile
+					errGo := withRetry(
+						ctx, j.file.Name, func() (err error) {
+							parkFile, err = downloadFile(ctx, drv, cfg, intoDir, j.file, syncCtx)
+							return err
+						},
+					)
+					results <- downloadResult{file: j.file, parkFile: parkFile, err: errGo}
 				}
 			},
 		)
 	}
 	go func() {
-		enqueueDownloadJobs(jobs, &syncCtx)
+		for _, f := range pending {
+			jobs <- job{f, syncCtx}
+		}
 		close(jobs)
 		logging.Debug("Finished enqueueing download jobs")
 	}()
@@ -83,49 +137,200 @@ func performInitialSync(ctx context.Context, q *sqlc.Queries, drv *drive.Service
 		close(results)
 	}()
 
-	for parkFile := range results {
-		err = q.UpsertFile(ctx, sqlc.UpsertFileParams{
-			Path:         parkFile.Path,
-			DriveID:      parkFile.FileId,
-			ContentHash:  parkFile.ContentHash,
-			LastModified: time.Now().Unix(),
-		})
+	numFailed := 0
+	for r := range results {
+		if r.err != nil {
+			relativePath, _ := downloadPath(cfg, r.file, syncCtx)
+			logging.Errorf("Could not download %s: %s", relativePath, r.err)
+			numFailed++
+			err = d.Queries().UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{
+				DriveID:     r.file.Id,
+				Path:        relativePath,
+				Error:       r.err.Error(),
+				LastAttempt: time.Now().Unix(),
+			})
+			if err != nil {
+				return 0, fmt.Errorf("could not persist failed download of '%s': %w", relativePath, err)
+			}
+			continue
+		}
+		err = d.WithTransaction(
+			ctx, func(q *sqlc.Queries) error {
+				return persistDownload(ctx, q, *r.parkFile)
+			},
+		)
 		if err != nil {
-			return fmt.Errorf("could not persist file: %w", err)
+			return 0, fmt.Errorf("could not persist file '%s': %w", r.parkFile.Path, err)
 		}
 	}
-
 	logging.Debug("Downloads finished!")
-	return nil
+
+	if err = linkShortcuts(ctx, d.Queries(), cfg, intoDir, syncCtx); err != nil {
+		return 0, fmt.Errorf("error linking shortcuts: %w", err)
+	}
+	return numFailed, nil
 }
 
-func walkFolder(ctx context.Context, drv *drive.Service, folderID, path string, syncCtx *syncContext) error {
-	pageToken := ""
-	for {
-		req := drv.Files.List().
-			Q(fmt.Sprintf("'%s' in parents and trashed=false", folderID)).
-			Fields("nextPageToken, files(id, name, mimeType, parents, shortcutDetails)").
-			PageSize(1000)
+// pendingDownloads returns the files of syncCtx that still have to be downloaded and records them as pending. A file
+// is skipped if a previous run downloaded the same revision to the same path and the local copy is unchanged.
+func pendingDownloads(
+	ctx context.Context,
+	q *sqlc.Queries,
+	cfg config.Config,
+	intoDir string,
+	syncCtx *syncContext,
+) ([]*drive.File, error) {
+	var pending []*drive.File
+	for _, f := range syncCtx.fileMap {
+		if f.MimeType == FolderMimeType {
+			continue
+		}
+		relativePath, _ := downloadPath(cfg, f, syncCtx)
+		if isUnexported(cfg, f.MimeType) {
+			logging.Debugf("Skipping: google apps file '%s' (%s) has no export format", relativePath, f.MimeType)
+			continue
+		}
 
-		if pageToken != "" {
-			req = req.PageToken(pageToken)
+		progress, err := q.GetInitialSyncFile(ctx, f.Id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return nil, fmt.Errorf("could not get initial sync progress of '%s': %w", relativePath, err)
+		case progress.Status == initialSyncDone &&
+			progress.Path == relativePath &&
+			progress.HeadRevision == f.HeadRevisionId &&
+			progress.RemoteVersion == f.Version:
+			hash, err := hashFile(filepath.Join(intoDir, relativePath))
+			if err == nil && bytes.Equal(hash, progress.ContentHash) {
+				logging.Debugf("Skipping %s, it was already downloaded", relativePath)
+				continue
+			}
 		}
 
-		r, err := req.Do()
+		err = q.UpsertInitialSyncFile(ctx, sqlc.UpsertInitialSyncFileParams{
+			DriveID:       f.Id,
+			Path:          relativePath,
+			HeadRevision:  f.HeadRevisionId,
+			RemoteVersion: f.Version,
+			ContentHash:   []byte{},
+			Status:        initialSyncPending,
+		})
 		if err != nil {
-			return fmt.Errorf("error listing files in %s: %w", path, err)
+			return nil, fmt.Errorf("could not persist initial sync progress of '%s': %w", relativePath, err)
 		}
+		pending = append(pending, f)
+	}
+	return pending, nil
+}
 
-		for _, f := range r.Files {
-			if err = handleFile(ctx, drv, f, folderID, path, syncCtx); err != nil {
-				logging.Errorf("error handling file %s: %s", f.Name, err)
-				continue
-			}
+// persistDownload tracks a file downloaded by the initial sync and records it as done.
+func persistDownload(ctx context.Context, q *sqlc.Queries, parkFile parkFile) error {
+	err := trackFile(ctx, q, parkFile)
+	if err != nil {
+		return err
+	}
+	err = q.UpsertInitialSyncFile(ctx, sqlc.UpsertInitialSyncFileParams{
+		DriveID:       parkFile.FileId,
+		Path:          parkFile.Path,
+		HeadRevision:  parkFile.HeadRevision,
+		RemoteVersion: parkFile.RemoteVersion,
+		ContentHash:   parkFile.ContentHash,
+		Status:        initialSyncDone,
+	})
+	if err != nil {
+		return err
+	}
+	// The file may have failed in a previous run
+	return q.DeleteFailedDownload(ctx, parkFile.FileId)
+}
+
+// trackFile records the downloaded parkFile as synced.
+func trackFile(ctx context.Context, q *sqlc.Queries, parkFile parkFile) error {
+	err := q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:          parkFile.Path,
+		DriveID:       parkFile.DriveID,
+		ContentHash:   parkFile.ContentHash,
+		LastModified:  time.Now().Unix(),
+		MimeType:      parkFile.MimeType,
+		HeadRevision:  parkFile.HeadRevision,
+		RemoteVersion: parkFile.RemoteVersion,
+		ExportFormat:  parkFile.ExportFormat,
+		RemoteMd5:     parkFile.RemoteMd5,
+	})
+	if err != nil {
+		return err
+	}
+	return q.SetShortcutID(ctx, sqlc.SetShortcutIDParams{ShortcutID: parkFile.ShortcutID, Path: parkFile.Path})
+}
+
+// collectFiles walks the root folder of cfg, the given shared drives and the files shared with the user and collects
+// the files selected for sync into a syncContext, as if they were synced to intoDir.
+func collectFiles(
+	ctx context.Context,
+	cfg config.Config,
+	drv gdrive.Remote,
+	intoDir string,
+	sharedDrives []config.SharedDrive,
+) (*syncContext, error) {
+	root, err := rootFolder(ctx, drv, cfg)
+	if err != nil {
+		return nil, err
+	}
+	roots := map[string]string{root.Id: ""}
+	for _, sd := range sharedDrives {
+		roots[sd.ID] = sd.LocalDir
+	}
+	sel, err := newSelection(ctx, drv, cfg, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	syncCtx := &syncContext{
+		fileMap:      make(map[string]*drive.File),
+		parents:      make(map[string]string),
+		visiting:     make(map[string]bool),
+		sources:      make(map[string]string),
+		followedBy:   make(map[string]string),
+		shortcutMode: cfg.ShortcutMode,
+		rootID:       root.Id,
+		rootDir:      intoDir,
+		selection:    sel,
+	}
+
+	err = walkFolder(ctx, drv, root.Id, intoDir, syncCtx)
+	if err != nil {
+		return nil, fmt.Errorf("error walking root folder: %w", err)
+	}
+	for _, sd := range sharedDrives {
+		if err = walkSharedDrive(ctx, drv, sd, intoDir, syncCtx); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SharedWithMe {
+		if err = walkSharedWithMe(ctx, drv, cfg, intoDir, syncCtx); err != nil {
+			return nil, err
 		}
+	}
+	return syncCtx, nil
+}
+
+func walkFolder(ctx context.Context, drv gdrive.Remote, folderID, path string, syncCtx *syncContext) error {
+	// Folder shortcuts may point to an ancestor of themselves, following them would never end
+	if syncCtx.visiting[folderID] {
+		logging.Infof("Skipping %s, it would create a shortcut loop", path)
+		return nil
+	}
+	syncCtx.visiting[folderID] = true
+	defer delete(syncCtx.visiting, folderID)
 
-		pageToken = r.NextPageToken
-		if pageToken == "" {
-			break
+	files, err := drv.ListChildren(ctx, folderID, syncCtx.driveID, walkFields)
+	if err != nil {
+		return fmt.Errorf("error listing files in %s: %w", path, err)
+	}
+	for _, f := range files {
+		if err = handleFile(ctx, drv, f, syncCtx.key(folderID), path, syncCtx); err != nil {
+			logging.Errorf("error handling file %s: %s", f.Name, err)
+			continue
 		}
 	}
 	return nil
@@ -133,24 +338,33 @@ func walkFolder(ctx context.Context, drv *drive.Service, folderID, path string,
 
 func handleFile(
 	ctx context.Context,
-	drv *drive.Service,
+	drv gdrive.Remote,
 	f *drive.File,
-	folderId, path string,
+	parentKey, path string,
 	syncCtx *syncContext,
 ) error {
-	if f.DriveId != "" {
+	if f.DriveId != syncCtx.driveID {
 		return nil
 	}
 
 	fullPath := filepath.Join(path, f.Name)
+	relativePath, err := filepath.Rel(syncCtx.rootDir, fullPath)
+	if err != nil {
+		return fmt.Errorf("could not determine relative path of %s: %w", f.Name, err)
+	}
+	isDir := f.MimeType == FolderMimeType ||
+		f.ShortcutDetails != nil && f.ShortcutDetails.TargetMimeType == FolderMimeType
+	if !syncCtx.selection.includes(relativePath, isDir) {
+		logging.Debugf("Skipping %s, it is not selected for sync", relativePath)
+		return nil
+	}
 
 	switch f.MimeType {
 	case FolderMimeType:
 		if err := walkFolder(ctx, drv, f.Id, fullPath, syncCtx); err != nil {
 			return fmt.Errorf("error walking folder %s: %w", f.Name, err)
 		}
-		syncCtx.parents[f.Id] = folderId
-		syncCtx.fileMap[f.Id] = f
+		syncCtx.track(f, f.Id, parentKey)
 	case ShortcutMimeType:
 		shortcut := f.ShortcutDetails
 		if shortcut == nil {
@@ -160,24 +374,40 @@ func handleFile(
 		targetID := shortcut.TargetId
 		targetType := shortcut.TargetMimeType
 
-		if targetType == FolderMimeType {
-			// TODO: keep track of visited ids to not get into a shortcut loop
-			if err := walkFolder(ctx, drv, targetID, filepath.Join(path, f.Name), syncCtx); err != nil {
+		switch {
+		case syncCtx.shortcutMode == config.ShortcutSkip:
+			logging.Debugf("Skipping shortcut %s", fullPath)
+		case syncCtx.shortcutMode == config.ShortcutSymlink:
+			// Shortcuts are linked once all targets are synced
+			syncCtx.shortcuts = append(syncCtx.shortcuts, shortcutLink{file: f, path: fullPath})
+		case targetType == FolderMimeType:
+			// The content of the target is synced to a folder named like the shortcut, its files are tracked by
+			// synthetic keys while walking, so they do not replace the same files synced at the location of the target
+			key := syncCtx.key(f.Id)
+			followed, followedTarget, followedShortcut := syncCtx.followed, syncCtx.followedTarget, syncCtx.followedShortcut
+			syncCtx.followed, syncCtx.followedTarget, syncCtx.followedShortcut = key, targetID, f.Id
+			err := walkFolder(ctx, drv, targetID, fullPath, syncCtx)
+			syncCtx.followed, syncCtx.followedTarget, syncCtx.followedShortcut = followed, followedTarget, followedShortcut
+			if err != nil {
 				return fmt.Errorf("error walking shortcut folder %s: %w", f.Name, err)
 			}
-			syncCtx.parents[targetID] = folderId
-			syncCtx.fileMap[targetID] = f
-		} else {
-			shortcutFile, err := drv.Files.Get(targetID).Fields("id, name").Do()
+			syncCtx.parents[key] = parentKey
+			syncCtx.fileMap[key] = &drive.File{Id: key, Name: f.Name, MimeType: FolderMimeType}
+			syncCtx.sources[key] = targetID
+			syncCtx.followedBy[key] = f.Id
+		default:
+			target, err := drv.Get(ctx, targetID, "id, name, mimeType, headRevisionId, version, webViewLink, "+
+				checksumFields)
 			if err != nil {
 				return fmt.Errorf("error getting shortcut target file %s: %w", f.Name, err)
 			}
-			syncCtx.parents[shortcutFile.Id] = folderId
-			syncCtx.fileMap[shortcutFile.Id] = shortcutFile
+			// The content of the target is synced to a file named like the shortcut
+			target.Name = f.Name
+			syncCtx.track(target, f.Id, parentKey)
+			syncCtx.followedBy[syncCtx.key(f.Id)] = f.Id
 		}
 	default:
-		syncCtx.parents[f.Id] = folderId
-		syncCtx.fileMap[f.Id] = f
+		syncCtx.track(f, f.Id, parentKey)
 	}
 
 	return nil
@@ -186,9 +416,70 @@ func handleFile(
 type syncContext struct {
 	fileMap map[string]*drive.File
 	parents map[string]string
+	// visiting holds the IDs of the folders on the path that is currently walked
+	visiting     map[string]bool
+	shortcutMode config.ShortcutMode
+	// driveID is the ID of the shared drive that is currently walked, empty for My Drive
+	driveID string
+	// rootID is the ID of the Drive folder synced to rootDir
+	rootID string
+	// rootDir is the directory the walked files are synced to
+	rootDir   string
+	selection selection
+	// shortcuts holds the shortcuts that are materialized as symlinks
+	shortcuts []shortcutLink
+	// followed is the key of the followed folder shortcut with the ID followedShortcut whose target followedTarget is
+	// currently walked
+	followed         string
+	followedTarget   string
+	followedShortcut string
+	// sources maps the keys of files tracked by another ID than their own to the Drive ID of their content
+	sources map[string]string
+	// followedBy maps the keys of files synced through a followed shortcut to the ID of that shortcut
+	followedBy map[string]string
+}
+
+// key returns the ID that the file with the given Drive ID is tracked by. Files below a followed folder shortcut are
+// tracked by synthetic IDs derived from the key of the shortcut.
+func (s *syncContext) key(id string) string {
+	switch {
+	case s.followed == "":
+		return id
+	case id == s.followedTarget:
+		return s.followed
+	}
+	return s.followed + "/" + id
+}
+
+// track records f by the key of id as child of the folder with the key parentKey.
+func (s *syncContext) track(f *drive.File, id, parentKey string) {
+	key := s.key(id)
+	if key != f.Id {
+		s.sources[key] = f.Id
+		tracked := *f
+		tracked.Id = key
+		f = &tracked
+	}
+	if s.followedShortcut != "" {
+		s.followedBy[key] = s.followedShortcut
+	}
+	s.parents[key] = parentKey
+	s.fileMap[key] = f
+}
+
+// source returns the Drive ID of the content of the file tracked by key.
+func (s *syncContext) source(key string) string {
+	if id, ok := s.sources[key]; ok {
+		return id
+	}
+	return key
 }
 
 func createDirs(intoDir string, syncCtx *syncContext) error {
+	// Files at the top level are downloaded into intoDir even if it contains no folders
+	if err := os.MkdirAll(intoDir, 0755); err != nil {
+		return err
+	}
 	files := slices.Collect(maps.Values(syncCtx.fileMap))
 	for _, f := range files {
 		if f.MimeType == FolderMimeType {
@@ -201,6 +492,26 @@ func createDirs(intoDir string, syncCtx *syncContext) error {
 	return nil
 }
 
+func persistDirs(ctx context.Context, q *sqlc.Queries, syncCtx *syncContext) error {
+	files := slices.Collect(maps.Values(syncCtx.fileMap))
+	for _, f := range files {
+		if f.MimeType != FolderMimeType {
+			continue
+		}
+		err := trackFile(ctx, q, parkFile{
+			Path:        localPath(f, syncCtx),
+			DriveID:     syncCtx.source(f.Id),
+			ShortcutID:  syncCtx.followedBy[f.Id],
+			ContentHash: []byte{},
+			MimeType:    f.MimeType,
+		})
+		if err != nil {
+			return fmt.Errorf("could not persist directory '%s': %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
 func localPath(file *drive.File, syncCtx *syncContext) string {
 	parents := syncCtx.parents
 	fileMap := syncCtx.fileMap
@@ -215,48 +526,56 @@ func localPath(file *drive.File, syncCtx *syncContext) string {
 	return f
 }
 
-func enqueueDownloadJobs(jobs chan<- job, syncCtx *syncContext) {
-	files := slices.Collect(maps.Values(syncCtx.fileMap))
-	for _, file := range files {
-		if file.MimeType != FolderMimeType {
-			jobs <- job{file, syncCtx}
+// collidesWithMyDrive reports whether the top-level directory of localDir is a top-level folder of My Drive.
+func collidesWithMyDrive(localDir string, syncCtx *syncContext) bool {
+	topLevel := strings.Split(localDir, string(filepath.Separator))[0]
+	for id, f := range syncCtx.fileMap {
+		if syncCtx.parents[id] == syncCtx.rootID && f.Name == topLevel {
+			return true
 		}
 	}
+	return false
 }
 
-func downloadFile(drv *drive.Service, rootDir string, f *drive.File, syncCtx *syncContext) (*parkFile, error) {
-	res, err := drv.Files.Get(f.Id).Download()
-	if err != nil {
-		return nil, fmt.Errorf("could not download file '%s': %w", f.Name, err)
-	}
-	defer func(Body io.ReadCloser) {
-		if err = Body.Close(); err != nil {
-			logging.Debugf("Could not close body: %s", err)
-		}
-	}(res.Body)
-
-	relativePath := localPath(f, syncCtx)
+func downloadFile(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	rootDir string,
+	f *drive.File,
+	syncCtx *syncContext,
+) (*parkFile, error) {
+	relativePath, exportFormat := downloadPath(cfg, f, syncCtx)
 	absoluteLocalPath := filepath.Join(rootDir, relativePath)
 	logging.Debugf("Downloading %s to %s", f.Name, absoluteLocalPath)
 
-	out, err := os.Create(absoluteLocalPath)
+	content := *f
+	content.Id = syncCtx.source(f.Id)
+	hash, err := fetchContent(ctx, drv, cfg, &content, absoluteLocalPath)
 	if err != nil {
-		return nil, fmt.Errorf("could not create file '%s': %w", absoluteLocalPath, err)
-	}
-
-	sha := crypto.SHA3_256.New()
-	_, err = io.Copy(io.MultiWriter(out, sha), res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("could not write file '%s': %w", absoluteLocalPath, err)
-	}
-	err = out.Close()
-	if err != nil {
-		return nil, fmt.Errorf("could not close file '%s': %w", absoluteLocalPath, err)
+		return nil, err
 	}
 
 	return &parkFile{
-		Path:        relativePath,
-		FileId:      f.Id,
-		ContentHash: sha.Sum(nil),
+		Path:          relativePath,
+		FileId:        f.Id,
+		DriveID:       content.Id,
+		ShortcutID:    syncCtx.followedBy[f.Id],
+		ContentHash:   hash,
+		MimeType:      f.MimeType,
+		HeadRevision:  f.HeadRevisionId,
+		RemoteVersion: f.Version,
+		ExportFormat:  exportFormat,
+		RemoteMd5:     f.Md5Checksum,
 	}, nil
 }
+
+// downloadPath returns the path relative to the root that f is downloaded to and the format it is exported to, if any.
+func downloadPath(cfg config.Config, f *drive.File, syncCtx *syncContext) (string, string) {
+	relativePath := localPath(f, syncCtx)
+	exportFormat := exportExtension(cfg, f.MimeType)
+	if exportFormat != "" {
+		relativePath += "." + exportFormat
+	}
+	return relativePath, exportFormat
+}
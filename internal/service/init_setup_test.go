@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/util"
+	"google.golang.org/api/googleapi"
+)
+
+// testConfig returns a config syncing My Drive to a temporary directory with the keys of a temporary profile.
+func testConfig(t *testing.T) config.Config {
+	t.Helper()
+	dataDir := t.TempDir()
+	return config.Config{
+		LocalDir:        t.TempDir(),
+		ConflictPolicy:  config.ConflictKeepBoth,
+		ShortcutMode:    config.ShortcutFollow,
+		DownloadWorkers: 2,
+		UploadWorkers:   1,
+		Dirs:            util.Dirs{Config: dataDir, Data: dataDir, Cache: t.TempDir(), Profile: dataDir},
+	}
+}
+
+// testDatabase opens the database in the data directory of cfg, it is closed when the test finishes.
+func testDatabase(t *testing.T, cfg config.Config) *db.Database {
+	t.Helper()
+	d, err := db.New(context.Background(), cfg.Dirs.Data)
+	if err != nil {
+		t.Fatalf("could not open database: %s", err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+// assertContent fails t unless the file at relativePath below dir has the given content.
+func assertContent(t *testing.T, dir, relativePath, content string) {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, relativePath))
+	if err != nil {
+		t.Fatalf("could not read %s: %s", relativePath, err)
+	}
+	if string(b) != content {
+		t.Errorf("content of %s = %q, want %q", relativePath, b, content)
+	}
+}
+
+func TestPerformInitialSyncFollowsShortcuts(t *testing.T) {
+	ctx := context.Background()
+	fake := gdrive.NewFake()
+	folder := fake.AddFolder(fake.RootID(), "A")
+	file := fake.AddFile(folder, "x.txt", []byte("x"))
+	fileShortcut := fake.AddShortcut(fake.RootID(), "x-link.txt", file)
+	folderShortcut := fake.AddShortcut(fake.RootID(), "A-link", folder)
+	cfg := testConfig(t)
+	d := testDatabase(t, cfg)
+
+	failed, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil)
+	if err != nil || failed != 0 {
+		t.Fatalf("performInitialSync() = %d, %v", failed, err)
+	}
+
+	tests := []struct {
+		path       string
+		driveID    string
+		shortcutID string
+	}{
+		{"A/x.txt", file, ""},
+		{"x-link.txt", file, fileShortcut},
+		{"A-link", folder, folderShortcut},
+		{"A-link/x.txt", file, folderShortcut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			tracked, err := d.Queries().GetFile(ctx, tt.path)
+			if err != nil {
+				t.Fatalf("%s is not tracked: %s", tt.path, err)
+			}
+			if tracked.DriveID != tt.driveID || tracked.ShortcutID != tt.shortcutID {
+				t.Errorf(
+					"%s is tracked as %s through %q, want %s through %q",
+					tt.path, tracked.DriveID, tracked.ShortcutID, tt.driveID, tt.shortcutID,
+				)
+			}
+			if tracked.MimeType != FolderMimeType {
+				assertContent(t, cfg.LocalDir, tt.path, "x")
+			}
+		})
+	}
+}
+
+func TestPerformInitialSync(t *testing.T) {
+	const documentMimeType = GoogleAppsMimeTypePrefix + "document"
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		// err is returned by the first download
+		err        error
+		want       map[string]string
+		wantFailed []string
+	}{
+		{
+			name: "all files",
+			want: map[string]string{"A/a.txt": "a", "b.txt": "b"},
+		},
+		{
+			name:      "excluded folder",
+			configure: func(cfg *config.Config) { cfg.SyncExclude = []string{"A"} },
+			want:      map[string]string{"b.txt": "b"},
+		},
+		{
+			name:      "included folder",
+			configure: func(cfg *config.Config) { cfg.SyncInclude = []string{"A"} },
+			want:      map[string]string{"A/a.txt": "a"},
+		},
+		{
+			name:      "exported document",
+			configure: func(cfg *config.Config) { cfg.ExportFormats = map[string]string{"document": "docx"} },
+			want:      map[string]string{"A/a.txt": "a", "A/doc.docx": "doc", "b.txt": "b"},
+		},
+		{
+			name:      "transient error is retried",
+			configure: func(cfg *config.Config) { cfg.SyncExclude = []string{"A"} },
+			err:       io.ErrUnexpectedEOF,
+			want:      map[string]string{"b.txt": "b"},
+		},
+		{
+			name:       "permanent error is recorded",
+			configure:  func(cfg *config.Config) { cfg.SyncExclude = []string{"A"} },
+			err:        &googleapi.Error{Code: http.StatusForbidden},
+			want:       map[string]string{},
+			wantFailed: []string{"b.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			ids := testTree(fake)
+			fake.AddDocument(ids["A"], "doc", documentMimeType, []byte("doc"))
+			cfg := testConfig(t)
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			d := testDatabase(t, cfg)
+			if tt.err != nil {
+				fake.InjectError("Download", tt.err)
+			}
+
+			failed, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil)
+			if err != nil || failed != len(tt.wantFailed) {
+				t.Fatalf("performInitialSync() = %d, %v, want %d failed downloads", failed, err, len(tt.wantFailed))
+			}
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), tt.want)
+			if got := failedDownloadPaths(t, d); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("failed downloads = %v, want %v", got, tt.wantFailed)
+			}
+		})
+	}
+}
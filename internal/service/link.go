@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/torfstack/park/internal/config"
+	"google.golang.org/api/drive/v3"
+)
+
+const desktopLinkDriveIDKey = "X-Park-Drive-Id"
+
+// maxLinkFileSize is the size above which local files are never taken for link files.
+const maxLinkFileSize = 64 << 10
+
+// desktopEntryEscaper escapes the values of desktop entries, which must not span lines, as the Desktop Entry
+// specification demands.
+var desktopEntryEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// linkExtensions maps the kinds of Google-native documents onto the extension of their JSON link files.
+var linkExtensions = map[string]string{
+	"document":     "gdoc",
+	"spreadsheet":  "gsheet",
+	"presentation": "gslides",
+	"drawing":      "gdraw",
+	"form":         "gform",
+	"map":          "gmap",
+	"site":         "gsite",
+	"jam":          "gjam",
+}
+
+// linkFile is the content of JSON link files, compatible with the ones created by the official client.
+type linkFile struct {
+	URL        string `json:"url"`
+	DocID      string `json:"doc_id"`
+	ResourceID string `json:"resource_id"`
+}
+
+// writeLinkFile writes a link file in the given format pointing to f to absoluteLocalPath and returns its SHA3-256
+// hash.
+func writeLinkFile(f *drive.File, format, absoluteLocalPath string) ([]byte, error) {
+	url := f.WebViewLink
+	if url == "" {
+		url = "https://drive.google.com/open?id=" + f.Id
+	}
+
+	var content []byte
+	if format == config.DesktopLinkFormat {
+		content = fmt.Appendf(
+			nil, "[Desktop Entry]\nType=Link\nName=%s\nURL=%s\nIcon=text-html\n%s=%s\n",
+			desktopEntryEscaper.Replace(f.Name), desktopEntryEscaper.Replace(url), desktopLinkDriveIDKey, f.Id,
+		)
+	} else {
+		kind := strings.TrimPrefix(f.MimeType, GoogleAppsMimeTypePrefix)
+		var err error
+		content, err = json.Marshal(linkFile{URL: url, DocID: f.Id, ResourceID: kind + ":" + f.Id})
+		if err != nil {
+			return nil, fmt.Errorf("could not create link file for '%s': %w", f.Name, err)
+		}
+	}
+
+	if err := os.WriteFile(absoluteLocalPath, content, 0644); err != nil {
+		return nil, fmt.Errorf("could not write link file '%s': %w", absoluteLocalPath, err)
+	}
+	sha := crypto.SHA3_256.New()
+	sha.Write(content)
+	return sha.Sum(nil), nil
+}
+
+// isLinkFile reports whether the local file at absoluteLocalPath is a link file pointing to a Google-native document,
+// whose content must never be uploaded. Link files are recognized by their content, other files with the extension
+// of a link file are uploaded like any other file.
+func isLinkFile(absoluteLocalPath string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(absoluteLocalPath), ".")
+	kind := ""
+	for k, linkExt := range linkExtensions {
+		if ext == linkExt {
+			kind = k
+		}
+	}
+	if kind == "" && ext != config.DesktopLinkFormat {
+		return false
+	}
+
+	f, err := os.Open(absoluteLocalPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, maxLinkFileSize+1))
+	if err != nil || len(content) > maxLinkFileSize {
+		return false
+	}
+	if ext == config.DesktopLinkFormat {
+		return bytes.Contains(content, []byte("\n"+desktopLinkDriveIDKey+"="))
+	}
+	var link linkFile
+	return json.Unmarshal(content, &link) == nil && link.DocID != "" && link.ResourceID == kind+":"+link.DocID
+}
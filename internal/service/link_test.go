@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/drive/v3"
+)
+
+func TestWriteLinkFileEscapesDesktopEntryName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     string
+	}{
+		{"plain", "Plan", "Name=Plan\n"},
+		{"newline", "Plan\nExec=rm -rf ~", `Name=Plan\nExec=rm -rf ~` + "\n"},
+		{"carriage return and tab", "a\rb\tc", `Name=a\rb\tc` + "\n"},
+		{"backslash", `C:\Docs\n`, `Name=C:\\Docs\\n` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "link.desktop")
+			f := &drive.File{Id: "id", Name: tt.fileName, WebViewLink: "https://example.com"}
+			if _, err := writeLinkFile(f, config.DesktopLinkFormat, path); err != nil {
+				t.Fatalf("writeLinkFile() = %v", err)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(b), "\n"+tt.want) {
+				t.Errorf("link file\n%s\ndoes not contain %q", b, tt.want)
+			}
+			if lines := strings.Count(string(b), "\n"); lines != 6 {
+				t.Errorf("link file\n%s\nhas %d lines, want 6", b, lines)
+			}
+		})
+	}
+}
+
+func TestIsLinkFile(t *testing.T) {
+	doc := &drive.File{Id: "id", Name: "Plan", MimeType: GoogleAppsMimeTypePrefix + "document"}
+	tests := []struct {
+		name string
+		// link is the format of the link file to doc written to the file, content is written instead if it is empty
+		link    string
+		file    string
+		content string
+		want    bool
+	}{
+		{name: "link file", link: config.LinkFormat, file: "Plan.gdoc", want: true},
+		{name: "desktop link file", link: config.DesktopLinkFormat, file: "Plan.desktop", want: true},
+		{name: "link file of another kind", link: config.LinkFormat, file: "Plan.gsheet"},
+		{name: "other file", file: "Plan.txt", content: `{"doc_id": "id", "resource_id": "document:id"}`},
+		{name: "own file with link extension", file: "notes.gdoc", content: "my notes"},
+		{name: "own JSON file with link extension", file: "notes.gdoc", content: `{"url": "https://example.com"}`},
+		{name: "own desktop file", file: "app.desktop", content: "[Desktop Entry]\nType=Application\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if tt.link != "" {
+				if _, err := writeLinkFile(doc, tt.link, path); err != nil {
+					t.Fatalf("writeLinkFile() = %v", err)
+				}
+			} else if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := isLinkFile(path); got != tt.want {
+				t.Errorf("isLinkFile(%s) = %t, want %t", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadOfFilesWithLinkExtension(t *testing.T) {
+	ctx := context.Background()
+	fake := gdrive.NewFake()
+	cfg := testConfig(t)
+	dmn := newTestDaemon(t, cfg, fake)
+	// Link files are not enabled by cfg, own files with their extensions are uploaded, link files never are
+	link := &drive.File{Id: "id", Name: "Plan", MimeType: GoogleAppsMimeTypePrefix + "document"}
+	if _, err := writeLinkFile(link, config.LinkFormat, filepath.Join(cfg.LocalDir, "Plan.gdoc")); err != nil {
+		t.Fatal(err)
+	}
+	changes := localChange{write: map[string]string{"notes.gdoc": "my notes", "sheet.gsheet": "{}"}}.apply(t, cfg.LocalDir)
+	changes.ops[filepath.Join(cfg.LocalDir, "Plan.gdoc")] |= fsnotify.Create
+
+	dmn.syncLocalChanges(ctx, changes)
+	assertFiles(t, "remote", remoteFiles(t, fake), map[string]string{"notes.gdoc": "my notes", "sheet.gsheet": "{}"})
+}
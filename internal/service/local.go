@@ -0,0 +1,421 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	// settleDelay is the time without further events after which collected local changes are processed
+	settleDelay = 2 * time.Second
+
+	uploadFields = "id, name, mimeType, headRevisionId, md5Checksum"
+	remoteFields = "id, name, mimeType, trashed, headRevisionId, version, modifiedTime, capabilities(canEdit), " +
+		checksumFields
+)
+
+// localChanges are the local paths that changed since the last sync.
+type localChanges struct {
+	// ops holds the operations seen for each absolute path
+	ops map[string]fsnotify.Op
+	// renames maps the absolute path a file was renamed to onto the absolute path it was renamed from
+	renames map[string]string
+}
+
+func newLocalChanges() localChanges {
+	return localChanges{
+		ops:     make(map[string]fsnotify.Op),
+		renames: make(map[string]string),
+	}
+}
+
+// consumeWatcherEvents collects local events until the directory settled and then syncs the affected paths.
+func (d *daemon) consumeWatcherEvents(ctx context.Context, c <-chan fsnotify.Event) {
+	pending := newLocalChanges()
+	timer := time.NewTimer(settleDelay)
+	timer.Stop()
+	// A rename produces a rename event for the old path that is immediately followed by a create event for the new one
+	lastRenamed := ""
+	for {
+		select {
+		case event, ok := <-c:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) &&
+				!event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			logging.Debugf("Received event: %s", event)
+			pending.ops[event.Name] |= event.Op
+			if event.Has(fsnotify.Create) && lastRenamed != "" {
+				pending.renames[event.Name] = lastRenamed
+			}
+			lastRenamed = ""
+			if event.Has(fsnotify.Rename) {
+				lastRenamed = event.Name
+			}
+			timer.Reset(settleDelay)
+		case <-timer.C:
+			d.syncLocalChanges(ctx, pending)
+			pending = newLocalChanges()
+			lastRenamed = ""
+		}
+	}
+}
+
+// syncLocalChanges syncs the current state of the given paths to Drive.
+func (d *daemon) syncLocalChanges(ctx context.Context, changes localChanges) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var removed, existing []string
+	// Sorting guarantees that directories are handled before their contents
+	for _, p := range slices.Sorted(maps.Keys(changes.ops)) {
+		relativePath, err := filepath.Rel(d.cfg.LocalDir, p)
+		if err != nil {
+			logging.Errorf("Could not determine relative path of %s: %s", p, err)
+			continue
+		}
+		info, err := os.Lstat(p)
+		switch {
+		case d.ignorer.Ignored(relativePath, err == nil && info.IsDir()):
+			logging.Debugf("Ignoring %s", relativePath)
+		case errors.Is(err, os.ErrNotExist):
+			if !isBelowAny(relativePath, removed) {
+				removed = append(removed, relativePath)
+			}
+		case err != nil:
+			logging.Errorf("Could not stat %s: %s", relativePath, err)
+		default:
+			existing = append(existing, relativePath)
+		}
+	}
+
+	moves, err := d.detectMoves(ctx, changes.renames, removed, existing)
+	if err != nil {
+		logging.Errorf("Could not detect moves: %s", err)
+	}
+	for oldPath, newPath := range moves {
+		if d.selection.includes(newPath, false) {
+			continue
+		}
+		// Files moved into an excluded folder are neither moved nor trashed on Drive, they are just no longer synced
+		logging.Infof("No longer syncing %s, it was moved to the excluded %s", oldPath, newPath)
+		if err = d.db.Queries().DeleteFilesUnder(ctx, oldPath); err != nil {
+			logging.Errorf("Could not stop syncing %s: %s", oldPath, err)
+		}
+	}
+	for _, oldPath := range slices.Sorted(maps.Keys(moves)) {
+		if !d.selection.includes(moves[oldPath], false) {
+			continue
+		}
+		if err = d.moveRemote(ctx, oldPath, moves[oldPath]); err != nil {
+			logging.Errorf("Could not move %s to %s: %s", oldPath, moves[oldPath], err)
+		}
+	}
+	removed = slices.DeleteFunc(removed, func(p string) bool { return moves[p] != "" })
+
+	// Directories are synced before and independently of the files, which are uploaded concurrently
+	var files []string
+	for _, p := range existing {
+		dir := isDir(filepath.Join(d.cfg.LocalDir, p))
+		if !d.selection.includes(p, dir) {
+			logging.Debugf("Not uploading %s, it is not selected for sync", p)
+			continue
+		}
+		if !dir {
+			files = append(files, p)
+			continue
+		}
+		if err = d.syncLocalPath(ctx, p); err != nil {
+			logging.Errorf("Could not sync %s: %s", p, err)
+		}
+	}
+	d.syncLocalFiles(ctx, files)
+
+	if err = d.trashRemoved(ctx, removed); err != nil {
+		logging.Errorf("Could not sync removals: %s", err)
+	}
+}
+
+// syncLocalFiles syncs the local files at the given paths using the configured number of upload workers.
+func (d *daemon) syncLocalFiles(ctx context.Context, relativePaths []string) {
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(max(d.cfg.UploadWorkers, 1), len(relativePaths)); i++ {
+		wg.Go(
+			func() {
+				for p := range paths {
+					if err := d.syncLocalPath(ctx, p); err != nil {
+						logging.Errorf("Could not sync %s: %s", p, err)
+					}
+				}
+			},
+		)
+	}
+	for _, p := range relativePaths {
+		paths <- p
+	}
+	close(paths)
+	wg.Wait()
+}
+
+func (d *daemon) syncLocalPath(ctx context.Context, relativePath string) error {
+	info, err := os.Lstat(filepath.Join(d.cfg.LocalDir, relativePath))
+	if err != nil {
+		return fmt.Errorf("could not stat: %w", err)
+	}
+
+	q := d.db.Queries()
+	if info.Mode().IsRegular() {
+		return d.uploadIfChanged(ctx, q, relativePath)
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	// Files created in a new directory before it was added to the watcher do not produce events of their own
+	return filepath.WalkDir(
+		filepath.Join(d.cfg.LocalDir, relativePath), func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(d.cfg.LocalDir, path)
+			if err != nil {
+				return err
+			}
+			if !d.selection.includes(rel, e.IsDir()) || d.ignorer.Ignored(rel, e.IsDir()) {
+				return skipEntry(e)
+			}
+			if e.IsDir() && d.isSharedDriveParent(rel) {
+				return nil
+			}
+			if e.IsDir() {
+				_, err = d.ensureRemoteFolder(ctx, q, rel)
+				return err
+			}
+			if !e.Type().IsRegular() {
+				return nil
+			}
+			return d.uploadIfChanged(ctx, q, rel)
+		},
+	)
+}
+
+// uploadIfChanged uploads the local file at relativePath if its content differs from the last synced state.
+func (d *daemon) uploadIfChanged(ctx context.Context, q *sqlc.Queries, relativePath string) error {
+	hash, err := hashFile(filepath.Join(d.cfg.LocalDir, relativePath))
+	if err != nil {
+		return fmt.Errorf("could not hash file: %w", err)
+	}
+
+	known, err := q.GetFile(ctx, relativePath)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if isLinkFile(filepath.Join(d.cfg.LocalDir, relativePath)) {
+			logging.Infof("Not uploading %s, it is a link to a Google document", relativePath)
+			return nil
+		}
+	case err != nil:
+		return fmt.Errorf("could not look up file: %w", err)
+	case known.ExportFormat != "":
+		logging.Debugf("Not uploading %s, it is an export of or a link to a Google document", relativePath)
+		return nil
+	case bytes.Equal(known.ContentHash, hash):
+		return nil
+	}
+	if conflicted, err := hasUnresolvedConflict(ctx, q, relativePath); conflicted || err != nil {
+		return err
+	}
+
+	if known.DriveID != "" {
+		remote, err := d.drv.Get(ctx, known.DriveID, remoteFields)
+		switch {
+		case isNotFound(err) || err == nil && remote.Trashed:
+			logging.Infof("%s was removed remotely, uploading local changes as a new file", relativePath)
+			known.DriveID = ""
+		case err != nil:
+			return fmt.Errorf("could not get remote file: %w", err)
+		case remote.Capabilities != nil && !remote.Capabilities.CanEdit:
+			logging.Infof("Not uploading %s, you are not allowed to edit it", relativePath)
+			return nil
+		case remote.HeadRevisionId != known.HeadRevision && !sameRemoteContent(known, remote):
+			return d.resolveConflict(ctx, q, known, remote, hash)
+		}
+	}
+
+	return d.uploadContent(ctx, q, relativePath, known.DriveID, hash)
+}
+
+// uploadContent uploads the local file at relativePath with the given hash as new content of the Drive file with
+// the given ID or as a new file if driveID is empty. Large files are uploaded using a resumable upload session.
+func (d *daemon) uploadContent(ctx context.Context, q *sqlc.Queries, relativePath, driveID string, hash []byte) error {
+	absoluteLocalPath := filepath.Join(d.cfg.LocalDir, relativePath)
+	info, err := os.Stat(absoluteLocalPath)
+	if err != nil {
+		return fmt.Errorf("could not stat file: %w", err)
+	}
+
+	metadata := &drive.File{}
+	if driveID == "" {
+		// Concurrent uploads into the same new folder must not create it twice
+		d.folders.Lock()
+		parentID, errParent := d.ensureRemoteFolder(ctx, q, filepath.Dir(relativePath))
+		d.folders.Unlock()
+		if errParent != nil {
+			return errParent
+		}
+		if parentID == sharedWithMeID {
+			return fmt.Errorf("only files shared with you can be located in '%s'", config.SharedWithMeDir)
+		}
+		metadata = &drive.File{Name: filepath.Base(relativePath), Parents: []string{parentID}}
+	}
+
+	var uploaded *drive.File
+	if info.Size() >= resumableThreshold {
+		logging.Debugf("Uploading %s in chunks", relativePath)
+		uploaded, err = d.uploadResumable(ctx, q, relativePath, driveID, metadata, hash)
+	} else {
+		uploaded, err = d.uploadSimple(ctx, absoluteLocalPath, driveID, metadata)
+	}
+	if err != nil {
+		return fmt.Errorf("could not upload file: %w", err)
+	}
+
+	err = q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:         relativePath,
+		DriveID:      uploaded.Id,
+		ContentHash:  hash,
+		LastModified: time.Now().Unix(),
+		MimeType:     uploaded.MimeType,
+		HeadRevision: uploaded.HeadRevisionId,
+		RemoteMd5:    uploaded.Md5Checksum,
+	})
+	if err != nil || driveID != "" {
+		return err
+	}
+	// A new file below the copy of a followed folder is created in the target of the shortcut
+	return inheritShortcutID(ctx, q, relativePath)
+}
+
+// uploadSimple uploads the file at absoluteLocalPath in a single request.
+func (d *daemon) uploadSimple(
+	ctx context.Context,
+	absoluteLocalPath, driveID string,
+	metadata *drive.File,
+) (*drive.File, error) {
+	in, err := os.Open(absoluteLocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer in.Close()
+
+	if driveID != "" {
+		logging.Debugf("Uploading new content of %s", absoluteLocalPath)
+		return d.drv.Update(ctx, driveID, metadata, throttleUpload(d.cfg, in), uploadFields)
+	}
+	logging.Debugf("Uploading new file %s", absoluteLocalPath)
+	return d.drv.Create(ctx, metadata, throttleUpload(d.cfg, in), uploadFields)
+}
+
+// ensureRemoteFolder returns the Drive ID of the folder at relativePath, creating it and its parents if necessary.
+func (d *daemon) ensureRemoteFolder(ctx context.Context, q *sqlc.Queries, relativePath string) (string, error) {
+	if relativePath == "." {
+		return d.rootID, nil
+	}
+	if relativePath == config.SharedWithMeDir && d.cfg.SharedWithMe {
+		return sharedWithMeID, nil
+	}
+
+	known, err := q.GetFile(ctx, relativePath)
+	if err == nil {
+		return known.DriveID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("could not look up folder '%s': %w", relativePath, err)
+	}
+
+	parentID, err := d.ensureRemoteFolder(ctx, q, filepath.Dir(relativePath))
+	if err != nil {
+		return "", err
+	}
+	if parentID == sharedWithMeID {
+		return "", fmt.Errorf("only folders shared with you can be located in '%s'", config.SharedWithMeDir)
+	}
+	logging.Debugf("Creating folder %s", relativePath)
+	folder, err := d.drv.Create(
+		ctx,
+		&drive.File{
+			Name:     filepath.Base(relativePath),
+			MimeType: FolderMimeType,
+			Parents:  []string{parentID},
+		},
+		nil,
+		"id",
+	)
+	if err != nil {
+		return "", fmt.Errorf("could not create folder '%s': %w", relativePath, err)
+	}
+
+	err = q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:         relativePath,
+		DriveID:      folder.Id,
+		ContentHash:  []byte{},
+		LastModified: time.Now().Unix(),
+		MimeType:     FolderMimeType,
+	})
+	if err == nil {
+		err = inheritShortcutID(ctx, q, relativePath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not persist folder '%s': %w", relativePath, err)
+	}
+	return folder.Id, nil
+}
+
+// skipEntry skips e while walking a directory.
+func skipEntry(e fs.DirEntry) error {
+	if e.IsDir() {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
+
+// hashFile returns the SHA3-256 hash of the file at path.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sha := crypto.SHA3_256.New()
+	if _, err = io.Copy(sha, f); err != nil {
+		return nil, err
+	}
+	return sha.Sum(nil), nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+)
+
+// detectMoves pairs locally removed tracked paths with newly created untracked paths and returns the moves as a map
+// from old to new relative path. Paths are paired by rename events first and by content hash second.
+func (d *daemon) detectMoves(
+	ctx context.Context,
+	renames map[string]string,
+	removed, existing []string,
+) (map[string]string, error) {
+	q := d.db.Queries()
+	moves := make(map[string]string)
+	moved := make(map[string]bool)
+
+	for newAbs, oldAbs := range renames {
+		oldPath, err := filepath.Rel(d.cfg.LocalDir, oldAbs)
+		if err != nil {
+			continue
+		}
+		newPath, err := filepath.Rel(d.cfg.LocalDir, newAbs)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(removed, oldPath) || !slices.Contains(existing, newPath) {
+			continue
+		}
+		oldTracked, err := isTracked(ctx, q, oldPath)
+		if err != nil {
+			return moves, err
+		}
+		newTracked, err := isTracked(ctx, q, newPath)
+		if err != nil {
+			return moves, err
+		}
+		if oldTracked && !newTracked {
+			moves[oldPath] = newPath
+			moved[newPath] = true
+		}
+	}
+
+	// Renames across watched directories or while the daemon was not running only show up as removal and creation
+	candidates := make(map[string][]string)
+	for _, p := range removed {
+		if moves[p] != "" {
+			continue
+		}
+		f, err := q.GetFile(ctx, p)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return moves, fmt.Errorf("could not look up '%s': %w", p, err)
+		}
+		// Folders and shortcuts have no content that could identify them
+		if f.MimeType != FolderMimeType && f.MimeType != ShortcutMimeType {
+			candidates[string(f.ContentHash)] = append(candidates[string(f.ContentHash)], p)
+		}
+	}
+	if len(candidates) == 0 {
+		return moves, nil
+	}
+
+	for _, p := range existing {
+		if moved[p] {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(d.cfg.LocalDir, p))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		tracked, err := isTracked(ctx, q, p)
+		if err != nil {
+			return moves, err
+		}
+		if tracked {
+			continue
+		}
+		hash, err := hashFile(filepath.Join(d.cfg.LocalDir, p))
+		if err != nil {
+			return moves, fmt.Errorf("could not hash '%s': %w", p, err)
+		}
+		if c := candidates[string(hash)]; len(c) == 1 {
+			moves[c[0]] = p
+			delete(candidates, string(hash))
+		}
+	}
+	return moves, nil
+}
+
+// moveRemote renames and reparents the Drive file of oldPath according to newPath, keeping its ID, revisions and
+// sharing settings.
+func (d *daemon) moveRemote(ctx context.Context, oldPath, newPath string) error {
+	q := d.db.Queries()
+	known, err := q.GetFile(ctx, oldPath)
+	if err != nil {
+		return fmt.Errorf("could not look up '%s': %w", oldPath, err)
+	}
+
+	newParentID, err := d.ensureRemoteFolder(ctx, q, filepath.Dir(newPath))
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(newPath)
+	if known.ExportFormat != "" {
+		// Exports carry the extension of their format locally, but not on Drive
+		name = strings.TrimSuffix(name, "."+known.ExportFormat)
+	}
+	root, err := isFollowedRoot(ctx, q, known)
+	if err != nil {
+		return err
+	}
+	driveID := known.DriveID
+	if root {
+		// Moving the copy of a followed shortcut moves the shortcut, not its target
+		driveID = known.ShortcutID
+	}
+	logging.Infof("Moving %s to %s", oldPath, newPath)
+	if err = d.drv.Move(ctx, driveID, name, newParentID); err != nil {
+		return fmt.Errorf("could not update '%s': %w", oldPath, err)
+	}
+
+	err = q.MoveFiles(ctx, sqlc.MoveFilesParams{NewPath: newPath, OldPath: oldPath})
+	if err != nil {
+		return fmt.Errorf("could not move '%s' in database: %w", oldPath, err)
+	}
+	if root {
+		return nil
+	}
+	// The file was moved into or out of the target of a followed shortcut if its new parent was synced through
+	// another shortcut than its old one
+	parent, err := q.GetFile(ctx, filepath.Dir(newPath))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not look up parent of '%s': %w", newPath, err)
+	}
+	err = q.UpdateShortcutIDUnder(ctx, sqlc.UpdateShortcutIDUnderParams{
+		NewShortcutID: parent.ShortcutID,
+		OldShortcutID: known.ShortcutID,
+		Path:          newPath,
+	})
+	if err != nil {
+		return fmt.Errorf("could not move '%s' in database: %w", oldPath, err)
+	}
+	return nil
+}
+
+func isTracked(ctx context.Context, q *sqlc.Queries, path string) (bool, error) {
+	_, err := q.GetFile(ctx, path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not look up '%s': %w", path, err)
+	}
+	return true, nil
+}
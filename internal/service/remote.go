@@ -0,0 +1,451 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	GoogleAppsMimeTypePrefix = "application/vnd.google-apps."
+
+	changeFields = "id, name, mimeType, parents, trashed, headRevisionId, version, driveId, modifiedTime, " +
+		"webViewLink, shortcutDetails, sharedWithMeTime, owners(emailAddress), " + checksumFields
+)
+
+// syncRemoteChanges applies all changes of My Drive and the synced shared drives since their persisted page tokens
+// to the local directory.
+func (d *daemon) syncRemoteChanges(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pageToken, err := d.db.Queries().GetPageToken(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get page token: %w", err)
+	}
+	if pageToken == "" {
+		return fmt.Errorf("no page token persisted, run `park init` first")
+	}
+	if err = d.syncDriveChanges(ctx, "", pageToken, persistPageToken); err != nil {
+		return err
+	}
+
+	sharedDrives, err := d.db.Queries().GetSharedDrives(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get shared drives: %w", err)
+	}
+	for _, sd := range sharedDrives {
+		err = d.syncDriveChanges(
+			ctx, sd.DriveID, sd.PageToken, func(ctx context.Context, q *sqlc.Queries, pageToken string) error {
+				return q.UpdateSharedDrivePageToken(
+					ctx, sqlc.UpdateSharedDrivePageTokenParams{PageToken: pageToken, DriveID: sd.DriveID},
+				)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("could not sync shared drive '%s': %w", sd.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncDriveChanges applies all changes of the shared drive with the given ID, or of My Drive if driveID is empty,
+// since pageToken. Changes are persisted one by one, so no transaction is held open while downloading. Downloads that
+// fail are recorded to be retried later, the page token that follows a page is only persisted once all of its changes
+// were applied or recorded, so an interrupted run applies the page again.
+func (d *daemon) syncDriveChanges(
+	ctx context.Context,
+	driveID, pageToken string,
+	persistToken func(context.Context, *sqlc.Queries, string) error,
+) error {
+	q := d.db.Queries()
+	for {
+		r, err := d.drv.Changes(ctx, pageToken, driveID, changeFields)
+		if err != nil {
+			return fmt.Errorf("could not list changes: %w", err)
+		}
+
+		for _, c := range r.Changes {
+			if err = d.applyChange(ctx, q, c); err != nil {
+				if err = d.recordFailedDownload(ctx, q, c.FileId, err); err != nil {
+					return fmt.Errorf("could not apply change for file '%s': %w", c.FileId, err)
+				}
+			}
+		}
+
+		nextToken := r.NextPageToken
+		if nextToken == "" {
+			nextToken = r.NewStartPageToken
+		}
+		if err = persistToken(ctx, q, nextToken); err != nil {
+			return fmt.Errorf("could not persist page token: %w", err)
+		}
+
+		if r.NextPageToken == "" {
+			return nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// recordFailedDownload records the file with the given ID as failed to download if err is a downloadError, so it is
+// retried by retryFailedDownloads. Other errors are returned as they are.
+func (d *daemon) recordFailedDownload(ctx context.Context, q *sqlc.Queries, driveID string, err error) error {
+	var dlErr *downloadError
+	if !errors.As(err, &dlErr) || ctx.Err() != nil {
+		return err
+	}
+	logging.Errorf("Could not download %s: %s", dlErr.path, dlErr.err)
+	err = q.UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{
+		DriveID:     driveID,
+		Path:        dlErr.path,
+		Error:       dlErr.err.Error(),
+		LastAttempt: time.Now().Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not persist failed download of '%s': %w", dlErr.path, err)
+	}
+	return nil
+}
+
+func (d *daemon) applyChange(ctx context.Context, q *sqlc.Queries, c *drive.Change) error {
+	if c.ChangeType == "drive" {
+		// Changes of the shared drive itself, e.g. its name, do not affect synced files
+		return nil
+	}
+	tracked, err := q.GetFilesByDriveID(ctx, c.FileId)
+	if err != nil {
+		return fmt.Errorf("could not look up file: %w", err)
+	}
+	existing, copies := splitCopies(tracked)
+	if err = d.applyToCopies(ctx, q, c, copies); err != nil {
+		return err
+	}
+
+	if c.Removed || c.File == nil || c.File.Trashed {
+		if err = d.removeFollowed(ctx, q, c.FileId); err != nil {
+			return err
+		}
+		return d.removeLocal(ctx, q, existing)
+	}
+
+	f := c.File
+	if _, synced := d.sharedDrives[f.DriveId]; f.DriveId != "" && !synced {
+		return nil
+	}
+
+	relativePath, ok, err := d.remotePath(ctx, q, f)
+	if err != nil {
+		return fmt.Errorf("could not resolve path: %w", err)
+	}
+	if !ok || !d.selection.includes(relativePath, f.MimeType == FolderMimeType) {
+		// The file left the synced tree, e.g. it was moved out of My Drive or into an excluded folder
+		return d.removeLocal(ctx, q, existing)
+	}
+	exportFormat := exportExtension(d.cfg, f.MimeType)
+	if exportFormat != "" {
+		relativePath += "." + exportFormat
+	}
+
+	if len(existing) == 1 && existing[0].Path != relativePath {
+		if err = d.moveLocal(ctx, q, existing[0].Path, relativePath); err != nil {
+			return err
+		}
+		existing[0].Path = relativePath
+	}
+
+	if f.MimeType == ShortcutMimeType {
+		return d.applyShortcut(ctx, q, relativePath, f)
+	}
+	return d.applyContent(ctx, q, relativePath, existing, f)
+}
+
+// applyContent syncs the remotely changed f to relativePath, where existing is tracked. Folders are created and
+// changed content is downloaded.
+func (d *daemon) applyContent(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath string,
+	existing []sqlc.File,
+	f *drive.File,
+) error {
+	exportFormat := exportExtension(d.cfg, f.MimeType)
+	switch {
+	case f.MimeType == FolderMimeType:
+		if err := os.MkdirAll(filepath.Join(d.cfg.LocalDir, relativePath), 0755); err != nil {
+			return fmt.Errorf("could not create directory '%s': %w", relativePath, err)
+		}
+		return q.UpsertFile(ctx, sqlc.UpsertFileParams{
+			Path:         relativePath,
+			DriveID:      f.Id,
+			ContentHash:  []byte{},
+			LastModified: time.Now().Unix(),
+			MimeType:     f.MimeType,
+		})
+	case exportFormat != "":
+		if len(existing) == 1 && existing[0].RemoteVersion == f.Version {
+			return nil
+		}
+		// Exports and link files are never uploaded, so local changes of them cannot conflict and are overwritten
+		return d.downloadTo(ctx, q, relativePath, f)
+	case strings.HasPrefix(f.MimeType, GoogleAppsMimeTypePrefix):
+		logging.Debugf("Skipping: google apps file '%s' (%s) has no export format", f.Name, f.MimeType)
+		return nil
+	}
+
+	if len(existing) == 1 && existing[0].HeadRevision == f.HeadRevisionId {
+		return nil
+	}
+	if len(existing) == 1 && sameRemoteContent(existing[0], f) {
+		// Only the metadata changed, there is nothing to download
+		return q.UpsertFile(ctx, sqlc.UpsertFileParams{
+			Path:          existing[0].Path,
+			DriveID:       f.Id,
+			ContentHash:   existing[0].ContentHash,
+			LastModified:  existing[0].LastModified,
+			MimeType:      f.MimeType,
+			HeadRevision:  f.HeadRevisionId,
+			RemoteVersion: f.Version,
+			ExportFormat:  existing[0].ExportFormat,
+			RemoteMd5:     f.Md5Checksum,
+		})
+	}
+
+	return d.downloadChange(ctx, q, relativePath, existing, f)
+}
+
+// downloadChange downloads the changed content of f to relativePath. If f is tracked as existing and its local copy
+// changed since the last sync as well, the conflict is resolved instead.
+func (d *daemon) downloadChange(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath string,
+	existing []sqlc.File,
+	f *drive.File,
+) error {
+	if len(existing) == 1 {
+		if conflicted, err := hasUnresolvedConflict(ctx, q, existing[0].Path); conflicted || err != nil {
+			return err
+		}
+		// The last synced state is the merge base, a conflict exists if the local file changed since then as well
+		localHash, err := hashFile(filepath.Join(d.cfg.LocalDir, existing[0].Path))
+		if err == nil && !bytes.Equal(localHash, existing[0].ContentHash) {
+			return d.resolveConflict(ctx, q, existing[0], f, localHash)
+		}
+	}
+
+	return d.downloadTo(ctx, q, relativePath, f)
+}
+
+// downloadError is returned if the content of a file could not be downloaded. Unlike other errors, it does not stop
+// remote sync, the download is recorded as failed and retried later.
+type downloadError struct {
+	path string
+	err  error
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *downloadError) Unwrap() error {
+	return e.err
+}
+
+// downloadTo downloads the content of f to relativePath and records it as synced.
+func (d *daemon) downloadTo(ctx context.Context, q *sqlc.Queries, relativePath string, f *drive.File) error {
+	hash, err := d.fetchTo(ctx, filepath.Join(d.cfg.LocalDir, relativePath), f)
+	if err != nil {
+		return &downloadError{path: relativePath, err: err}
+	}
+	return d.recordDownload(ctx, q, relativePath, f, hash)
+}
+
+// fetchTo downloads the content of f to absoluteLocalPath and returns its hash.
+func (d *daemon) fetchTo(ctx context.Context, absoluteLocalPath string, f *drive.File) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(absoluteLocalPath), 0755); err != nil {
+		return nil, fmt.Errorf("could not create parent directory of '%s': %w", absoluteLocalPath, err)
+	}
+	logging.Debugf("Downloading remote change of %s to %s", f.Name, absoluteLocalPath)
+	var hash []byte
+	err := withRetry(
+		ctx, f.Name, func() (err error) {
+			hash, err = fetchContent(ctx, d.drv, d.cfg, f, absoluteLocalPath)
+			return err
+		},
+	)
+	return hash, err
+}
+
+// recordDownload records the content of f with the given hash, downloaded to relativePath, as synced.
+func (d *daemon) recordDownload(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath string,
+	f *drive.File,
+	hash []byte,
+) error {
+	err := q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:          relativePath,
+		DriveID:       f.Id,
+		ContentHash:   hash,
+		LastModified:  time.Now().Unix(),
+		MimeType:      f.MimeType,
+		HeadRevision:  f.HeadRevisionId,
+		RemoteVersion: f.Version,
+		ExportFormat:  exportExtension(d.cfg, f.MimeType),
+		RemoteMd5:     f.Md5Checksum,
+	})
+	if err != nil {
+		return err
+	}
+	return q.DeleteFailedDownload(ctx, f.Id)
+}
+
+// remotePath resolves the path of f relative to the local directory. It returns false if f is not located below
+// the synced root folder.
+func (d *daemon) remotePath(ctx context.Context, q *sqlc.Queries, f *drive.File) (string, bool, error) {
+	if len(f.Parents) == 0 {
+		// The parents of files shared with the user are not visible unless they were shared as well
+		path, ok := d.sharedWithMePath(f)
+		return path, ok, nil
+	}
+	parentID := f.Parents[0]
+	if parentID == d.rootID {
+		return f.Name, true, nil
+	}
+
+	tracked, err := q.GetFilesByDriveID(ctx, parentID)
+	if err != nil {
+		return "", false, fmt.Errorf("could not look up parent: %w", err)
+	}
+	// Copies of the parent synced through followed shortcuts are synced by applyToCopies
+	if known, _ := splitCopies(tracked); len(known) > 0 {
+		return filepath.Join(known[0].Path, f.Name), true, nil
+	}
+
+	parent, err := d.drv.Get(ctx, parentID, "id, name, parents, sharedWithMeTime, owners(emailAddress)")
+	if isNotFound(err) {
+		path, ok := d.sharedWithMePath(f)
+		return path, ok, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not get parent '%s': %w", parentID, err)
+	}
+	parentPath, ok, err := d.remotePath(ctx, q, parent)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return filepath.Join(parentPath, f.Name), true, nil
+}
+
+func (d *daemon) removeLocal(ctx context.Context, q *sqlc.Queries, files []sqlc.File) error {
+	for _, f := range files {
+		changed, err := d.hasUnsyncedChanges(ctx, q, f.Path)
+		if err != nil {
+			return fmt.Errorf("could not check '%s' for local changes: %w", f.Path, err)
+		}
+		if changed {
+			// Local changes win over remote removals, the file is uploaded again as a new file
+			logging.Infof("Keeping %s although it was removed remotely, it has local changes", f.Path)
+		} else {
+			logging.Debugf("Removing %s, it was removed remotely", f.Path)
+			err = os.RemoveAll(filepath.Join(d.cfg.LocalDir, f.Path))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("could not remove '%s': %w", f.Path, err)
+			}
+		}
+		if err = q.DeleteFilesUnder(ctx, f.Path); err != nil {
+			return fmt.Errorf("could not delete '%s' from database: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func (d *daemon) moveLocal(ctx context.Context, q *sqlc.Queries, oldPath, newPath string) error {
+	logging.Debugf("Moving %s to %s, it was moved remotely", oldPath, newPath)
+	absoluteNewPath := filepath.Join(d.cfg.LocalDir, newPath)
+	if err := os.MkdirAll(filepath.Dir(absoluteNewPath), 0755); err != nil {
+		return fmt.Errorf("could not create parent directory of '%s': %w", newPath, err)
+	}
+	err := os.Rename(filepath.Join(d.cfg.LocalDir, oldPath), absoluteNewPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not move '%s' to '%s': %w", oldPath, newPath, err)
+	}
+	err = q.MoveFiles(ctx, sqlc.MoveFilesParams{NewPath: newPath, OldPath: oldPath})
+	if err != nil {
+		return fmt.Errorf("could not move '%s' in database: %w", oldPath, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+func isGone(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
+}
+
+func isRangeNotSatisfiable(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusRequestedRangeNotSatisfiable
+}
+
+func isForbidden(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+// hasUnsyncedChanges reports whether the local file at relativePath or any file below it was created or modified
+// since it was last synced.
+func (d *daemon) hasUnsyncedChanges(ctx context.Context, q *sqlc.Queries, relativePath string) (bool, error) {
+	changed := false
+	err := filepath.WalkDir(
+		filepath.Join(d.cfg.LocalDir, relativePath), func(path string, e fs.DirEntry, err error) error {
+			if err != nil || !e.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(d.cfg.LocalDir, path)
+			if err != nil || d.ignorer.Ignored(rel, false) {
+				return err
+			}
+			known, err := q.GetFile(ctx, rel)
+			if errors.Is(err, sql.ErrNoRows) {
+				changed = true
+				return fs.SkipAll
+			}
+			if err != nil {
+				return err
+			}
+			hash, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(hash, known.ContentHash) {
+				changed = true
+				return fs.SkipAll
+			}
+			return nil
+		},
+	)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return changed, err
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+	"github.com/torfstack/park/internal/util"
+)
+
+// PrintConflicts writes the unresolved conflicts to out, or all recorded conflicts if all is set. Conflicts of sync
+// pairs are listed with their absolute path.
+func PrintConflicts(ctx context.Context, dirs util.Dirs, out io.Writer, all bool) error {
+	conflicts, err := loadConflicts(ctx, dirs.Data, all)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := config.Stored(ctx, dirs)
+	if err != nil {
+		return fmt.Errorf("could not get config: %w", err)
+	}
+	for _, p := range cfg.Pairs() {
+		pairConflicts, err := loadConflicts(ctx, p.Dirs.Data, all)
+		if err != nil {
+			return fmt.Errorf("sync pair %s: %w", p.LocalDir, err)
+		}
+		for _, c := range pairConflicts {
+			c.Path = filepath.Join(p.LocalDir, c.Path)
+			conflicts = append(conflicts, c)
+		}
+	}
+	if len(conflicts) == 0 {
+		logging.Info("No conflicts!")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PATH\tSIDE\tMODIFIED\tSIZE\tHASH\tRESOLUTION")
+	for _, c := range conflicts {
+		resolution := c.Resolution
+		if resolution == "" {
+			resolution = "unresolved"
+		}
+		_, _ = fmt.Fprintf(
+			w, "%s\tlocal\t%s\t%d\tsha3:%s\t%s\n",
+			c.Path, formatUnix(c.LocalModified), c.LocalSize, shortHash(hex.EncodeToString(c.LocalHash)), resolution,
+		)
+		_, _ = fmt.Fprintf(
+			w, "\tremote\t%s\t%d\tmd5:%s\t\n",
+			formatUnix(c.RemoteModified), c.RemoteSize, shortHash(c.RemoteMd5),
+		)
+	}
+	return w.Flush()
+}
+
+func loadConflicts(ctx context.Context, dataDir string, all bool) ([]sqlc.Conflict, error) {
+	d, err := db.New(ctx, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+
+	var conflicts []sqlc.Conflict
+	if all {
+		conflicts, err = d.Queries().GetConflicts(ctx)
+	} else {
+		conflicts, err = d.Queries().GetUnresolvedConflicts(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get conflicts: %w", err)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict resolves the unresolved conflict of the file at path by keeping the local version, the remote
+// version or both of them. If cfg has sync pairs, a relative path is relative to the current directory.
+func ResolveConflict(
+	ctx context.Context,
+	cfg config.Config,
+	drv gdrive.Remote,
+	path string,
+	keep config.ConflictPolicy,
+) error {
+	if len(cfg.SyncPairs) > 0 {
+		absolutePath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("could not resolve '%s': %w", path, err)
+		}
+		pair, ok := cfg.PairOf(absolutePath)
+		if !ok {
+			return fmt.Errorf("'%s' is not located in the local directory of a sync pair", path)
+		}
+		return ResolveConflict(ctx, pair, drv, absolutePath, keep)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+
+	relativePath := path
+	if filepath.IsAbs(path) {
+		relativePath, err = filepath.Rel(cfg.LocalDir, path)
+		if err != nil {
+			return fmt.Errorf("'%s' is not located in '%s': %w", path, cfg.LocalDir, err)
+		}
+	}
+
+	dmn, err := newDaemon(ctx, cfg, d, drv)
+	if err != nil {
+		return err
+	}
+
+	return d.WithTransaction(
+		ctx, func(q *sqlc.Queries) error {
+			conflict, err := q.GetUnresolvedConflict(ctx, relativePath)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("'%s' has no unresolved conflict", relativePath)
+			}
+			if err != nil {
+				return fmt.Errorf("could not get conflict: %w", err)
+			}
+			known, err := q.GetFile(ctx, relativePath)
+			if err != nil {
+				return fmt.Errorf("could not get file: %w", err)
+			}
+			remote, err := drv.Get(ctx, known.DriveID, remoteFields)
+			if err != nil {
+				return fmt.Errorf("could not get remote file: %w", err)
+			}
+
+			copyPath := ""
+			switch keep {
+			case config.ConflictPreferLocal:
+				hash, errHash := hashFile(filepath.Join(cfg.LocalDir, relativePath))
+				if errHash != nil {
+					return fmt.Errorf("could not hash file: %w", errHash)
+				}
+				err = dmn.uploadContent(ctx, q, relativePath, known.DriveID, hash)
+			case config.ConflictPreferRemote:
+				err = dmn.downloadTo(ctx, q, relativePath, remote)
+			case config.ConflictKeepBoth:
+				hash, errHash := hashFile(filepath.Join(cfg.LocalDir, relativePath))
+				if errHash != nil {
+					return fmt.Errorf("could not hash file: %w", errHash)
+				}
+				copyPath, err = dmn.keepBoth(ctx, q, relativePath, remote, hash, time.Now())
+			default:
+				return fmt.Errorf("invalid resolution '%s'", keep)
+			}
+			if err != nil {
+				return fmt.Errorf("could not resolve conflict: %w", err)
+			}
+
+			err = q.ResolveConflict(ctx, sqlc.ResolveConflictParams{
+				Resolution:   string(keep),
+				ConflictCopy: copyPath,
+				ResolvedAt:   time.Now().Unix(),
+				ID:           conflict.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("could not persist resolution: %w", err)
+			}
+			logging.Infof("Resolved conflict of %s", relativePath)
+			return nil
+		},
+	)
+}
+
+func formatUnix(t int64) string {
+	if t == 0 {
+		return "-"
+	}
+	return time.Unix(t, 0).Format(time.DateTime)
+}
+
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
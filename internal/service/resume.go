@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+// Resume resolves the local removals that paused sync and unpauses it. If allowDeletions is set, the removed files
+// are moved to the Drive trash, otherwise they are restored from Drive. If cfg has sync pairs, each paused pair is
+// resumed.
+func Resume(ctx context.Context, cfg config.Config, drv gdrive.Remote, allowDeletions bool) error {
+	if len(cfg.SyncPairs) > 0 {
+		return resumePairs(ctx, cfg, drv, allowDeletions)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+
+	q := d.Queries()
+	isPaused, err := q.IsPaused(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check if sync is paused: %w", err)
+	}
+	if !isPaused {
+		logging.Info("Sync is not paused!")
+		return nil
+	}
+
+	files, err := q.GetAllFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get files: %w", err)
+	}
+	var handled []string
+	for _, f := range files {
+		if isBelowAny(f.Path, handled) {
+			continue
+		}
+		absoluteLocalPath := filepath.Join(cfg.LocalDir, f.Path)
+		if _, err = os.Lstat(absoluteLocalPath); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if allowDeletions {
+			logging.Infof("Trashing %s", f.Path)
+			if err = trashFile(ctx, drv, q, f); err != nil {
+				return err
+			}
+			handled = append(handled, f.Path)
+			continue
+		}
+		logging.Infof("Restoring %s", f.Path)
+		if err = restoreFile(ctx, cfg, drv, q, f, absoluteLocalPath); err != nil {
+			return err
+		}
+	}
+
+	if err = q.SetPaused(ctx, false); err != nil {
+		return fmt.Errorf("could not resume sync: %w", err)
+	}
+	logging.Info("Sync resumed, restart `park daemon`")
+	return nil
+}
+
+func restoreFile(
+	ctx context.Context,
+	cfg config.Config,
+	drv gdrive.Remote,
+	q *sqlc.Queries,
+	f sqlc.File,
+	absoluteLocalPath string,
+) error {
+	if f.MimeType == FolderMimeType {
+		if err := os.MkdirAll(absoluteLocalPath, 0755); err != nil {
+			return fmt.Errorf("could not restore directory '%s': %w", f.Path, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(absoluteLocalPath), 0755); err != nil {
+		return fmt.Errorf("could not create parent directory of '%s': %w", f.Path, err)
+	}
+	name := filepath.Base(f.Path)
+	if f.ExportFormat != "" {
+		name = strings.TrimSuffix(name, "."+f.ExportFormat)
+	}
+	remote := &drive.File{Id: f.DriveID, Name: name, MimeType: f.MimeType, Md5Checksum: f.RemoteMd5}
+	hash, err := fetchContent(ctx, drv, cfg, remote, absoluteLocalPath)
+	if err != nil {
+		return fmt.Errorf("could not restore '%s': %w", f.Path, err)
+	}
+	err = q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:          f.Path,
+		DriveID:       f.DriveID,
+		ContentHash:   hash,
+		LastModified:  time.Now().Unix(),
+		MimeType:      f.MimeType,
+		HeadRevision:  f.HeadRevision,
+		RemoteVersion: f.RemoteVersion,
+		ExportFormat:  f.ExportFormat,
+		RemoteMd5:     f.RemoteMd5,
+	})
+	if err != nil {
+		return fmt.Errorf("could not persist '%s': %w", f.Path, err)
+	}
+	return nil
+}
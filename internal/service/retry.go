@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+)
+
+const (
+	// The transport already retries requests the Drive API rejected, these only cover failures it cannot see, such as
+	// a transfer that broke off or arrived corrupted
+	maxTransferAttempts    = 5
+	initialTransferBackoff = time.Second
+	maxTransferBackoff     = 32 * time.Second
+
+	retryFields = remoteFields + ", parents, driveId, webViewLink, sharedWithMeTime, owners(emailAddress)"
+)
+
+// withRetry calls fn until it succeeds, fails with an error that is not transient or was called maxTransferAttempts
+// times. The delay between attempts grows exponentially, starting at initialTransferBackoff.
+func withRetry(ctx context.Context, what string, fn func() error) error {
+	backoff := initialTransferBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if attempt == maxTransferAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		// Jitter keeps concurrent workers from retrying in lockstep
+		delay := backoff + rand.N(backoff/2)
+		logging.Debugf("Retrying %s in %s, attempt %d failed: %s", what, delay.Round(time.Millisecond), attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, maxTransferBackoff)
+	}
+}
+
+// isTransient reports whether err is likely to go away when the transfer is retried, e.g. because of a dropped
+// connection. Errors the Drive API responded with are not, the transport retried those already.
+func isTransient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		// The content was likely corrupted in transit
+		errors.Is(err, errChecksumMismatch) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryFailedDownloads downloads the files that could not be downloaded before, e.g. during the initial sync. A file
+// is retried once the delay grown with its number of failed attempts passed since its last attempt.
+func (d *daemon) retryFailedDownloads(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	failed, err := d.db.Queries().GetFailedDownloads(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get failed downloads: %w", err)
+	}
+	for _, fd := range failed {
+		if time.Since(time.Unix(fd.LastAttempt, 0)) < failedDownloadDelay(fd.Attempts) {
+			continue
+		}
+		// No transaction is held open while downloading, the file is persisted on its own once it was downloaded
+		err = d.retryDownload(ctx, d.db.Queries(), fd)
+		if err == nil {
+			continue
+		}
+		logging.Errorf("Could not download %s: %s", fd.Path, err)
+		err = d.db.Queries().UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{
+			DriveID:     fd.DriveID,
+			Path:        fd.Path,
+			Error:       err.Error(),
+			LastAttempt: time.Now().Unix(),
+		})
+		if err != nil {
+			return fmt.Errorf("could not persist failed download of '%s': %w", fd.Path, err)
+		}
+	}
+	return nil
+}
+
+// retryDownload downloads the file of fd to its current path. Files that no longer exist or are no longer selected
+// for sync are forgotten.
+func (d *daemon) retryDownload(ctx context.Context, q *sqlc.Queries, fd sqlc.FailedDownload) error {
+	if isFollowedCopy(fd.DriveID) {
+		return d.retryCopy(ctx, q, fd)
+	}
+	f, err := d.drv.Get(ctx, fd.DriveID, retryFields)
+	if isNotFound(err) || err == nil && f.Trashed {
+		logging.Debugf("Not retrying %s, it was removed remotely", fd.Path)
+		return q.DeleteFailedDownload(ctx, fd.DriveID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get file: %w", err)
+	}
+	if f.MimeType == ShortcutMimeType {
+		// The target of a followed file shortcut failed to download during the initial sync
+		return d.retryCopy(ctx, q, fd)
+	}
+
+	relativePath, ok, err := d.remotePath(ctx, q, f)
+	if err != nil {
+		return fmt.Errorf("could not resolve path: %w", err)
+	}
+	exportFormat := exportExtension(d.cfg, f.MimeType)
+	if !ok || !d.selection.includes(relativePath, false) || d.ignorer.Ignored(relativePath, false) ||
+		exportFormat == "" && strings.HasPrefix(f.MimeType, GoogleAppsMimeTypePrefix) {
+		logging.Debugf("Not retrying %s, it is no longer synced", fd.Path)
+		return q.DeleteFailedDownload(ctx, fd.DriveID)
+	}
+	if exportFormat != "" {
+		relativePath += "." + exportFormat
+	}
+	logging.Infof("Retrying download of %s", relativePath)
+	if exportFormat != "" {
+		// Exports and link files are never uploaded, so local changes of them cannot conflict and are overwritten
+		return d.downloadTo(ctx, q, relativePath, f)
+	}
+
+	tracked, err := q.GetFilesByDriveID(ctx, f.Id)
+	if err != nil {
+		return fmt.Errorf("could not look up file: %w", err)
+	}
+	existing, _ := splitCopies(tracked)
+	// The file may have been synced and changed locally since the download failed
+	return d.downloadChange(ctx, q, relativePath, existing, f)
+}
+
+// failedDownloadDelay returns the time to wait before retrying a download that failed the given number of times.
+func failedDownloadDelay(attempts int64) time.Duration {
+	return min(time.Minute<<min(attempts, 10), 12*time.Hour)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+)
+
+func TestRetryFailedDownloadsKeepsLocalChanges(t *testing.T) {
+	ctx := context.Background()
+	fake := gdrive.NewFake()
+	id := fake.AddFile(fake.RootID(), "x.txt", []byte("base"))
+	cfg := testConfig(t)
+	cfg.ConflictPolicy = config.ConflictManual
+	d := testDatabase(t, cfg)
+	if _, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil); err != nil {
+		t.Fatalf("performInitialSync() = %v", err)
+	}
+	dmn, err := newDaemon(ctx, cfg, d, fake)
+	if err != nil {
+		t.Fatalf("newDaemon() = %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(cfg.LocalDir, "x.txt"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = fake.SetContent(id, []byte("remote")); err != nil {
+		t.Fatal(err)
+	}
+	err = d.Queries().UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{DriveID: id, Path: "x.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = dmn.retryFailedDownloads(ctx); err != nil {
+		t.Fatalf("retryFailedDownloads() = %v", err)
+	}
+	assertContent(t, cfg.LocalDir, "x.txt", "local")
+	if _, err = d.Queries().GetUnresolvedConflict(ctx, "x.txt"); err != nil {
+		t.Errorf("x.txt has no unresolved conflict: %s", err)
+	}
+}
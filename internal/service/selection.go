@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+)
+
+// selection determines which paths, relative to the local directory, are synced.
+type selection struct {
+	include []string
+	exclude []string
+}
+
+// newSelection resolves the folders to include and exclude configured in cfg to paths relative to the local
+// directory. roots maps the IDs of the synced root folders onto their directory relative to the local directory.
+func newSelection(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	roots map[string]string,
+) (selection, error) {
+	var err error
+	s := selection{}
+	s.include, err = resolveFolders(ctx, drv, cfg.SyncInclude, roots)
+	if err != nil {
+		return s, fmt.Errorf("could not resolve included folders: %w", err)
+	}
+	s.exclude, err = resolveFolders(ctx, drv, cfg.SyncExclude, roots)
+	if err != nil {
+		return s, fmt.Errorf("could not resolve excluded folders: %w", err)
+	}
+	return s, nil
+}
+
+// includes reports whether the file or directory at relativePath is synced. Directories containing an included
+// folder are synced as well, but not their other content.
+func (s selection) includes(relativePath string, isDir bool) bool {
+	if isBelowAny(relativePath, s.exclude) {
+		return false
+	}
+	if len(s.include) == 0 || isBelowAny(relativePath, s.include) {
+		return true
+	}
+	return isDir && slices.ContainsFunc(s.include, func(inc string) bool { return isBelow(inc, relativePath) })
+}
+
+func resolveFolders(
+	ctx context.Context,
+	drv gdrive.Remote,
+	folders []string,
+	roots map[string]string,
+) ([]string, error) {
+	paths := make([]string, 0, len(folders))
+	for _, f := range folders {
+		id, ok := strings.CutPrefix(f, config.FolderIDPrefix)
+		if !ok {
+			paths = append(paths, f)
+			continue
+		}
+		p, err := drivePath(ctx, drv, id, roots)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// drivePath resolves the path of the Drive file with the given ID relative to the local directory by walking up its
+// parents until one of the roots is reached.
+func drivePath(ctx context.Context, drv gdrive.Remote, id string, roots map[string]string) (string, error) {
+	var names []string
+	for {
+		if root, ok := roots[id]; ok {
+			slices.Reverse(names)
+			return filepath.Join(append([]string{root}, names...)...), nil
+		}
+		f, err := drv.Get(ctx, id, "id, name, parents")
+		if err != nil {
+			return "", fmt.Errorf("could not get folder '%s': %w", id, err)
+		}
+		if len(f.Parents) == 0 {
+			return "", fmt.Errorf("folder '%s' is not located in a synced drive", id)
+		}
+		names = append(names, f.Name)
+		id = f.Parents[0]
+	}
+}
+
+// ApplySelection applies a changed selection of synced folders: files that are no longer selected stop being synced
+// and are removed locally if removeExcluded is set, files of newly selected folders are downloaded. If cfg has sync
+// pairs, the pairs that were added are synced.
+func ApplySelection(
+	ctx context.Context,
+	cfg config.Config,
+	drv gdrive.Remote,
+	removeExcluded bool,
+) error {
+	if len(cfg.SyncPairs) > 0 {
+		// The folders of sync pairs are synced completely, only pairs that were added need to be synced
+		return initialSyncPairs(ctx, cfg, drv)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+
+	dmn, err := newDaemon(ctx, cfg, d, drv)
+	if err != nil {
+		return err
+	}
+
+	err = d.WithTransaction(
+		ctx, func(q *sqlc.Queries) error {
+			return dmn.dropExcluded(ctx, q, removeExcluded)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	// Downloads take long, holding a transaction while they run would block the database for all that time
+	return dmn.downloadIncluded(ctx)
+}
+
+func (d *daemon) dropExcluded(ctx context.Context, q *sqlc.Queries, removeExcluded bool) error {
+	files, err := q.GetAllFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get files: %w", err)
+	}
+	var dropped []string
+	for _, f := range files {
+		if d.selection.includes(f.Path, f.MimeType == FolderMimeType) || isBelowAny(f.Path, dropped) {
+			continue
+		}
+		dropped = append(dropped, f.Path)
+		logging.Infof("No longer syncing %s, it is not selected for sync", f.Path)
+
+		if removeExcluded {
+			changed, err := d.hasUnsyncedChanges(ctx, q, f.Path)
+			if err != nil {
+				return fmt.Errorf("could not check '%s' for local changes: %w", f.Path, err)
+			}
+			if changed {
+				logging.Infof("Keeping %s locally, it has changes that were not synced", f.Path)
+			} else if err = os.RemoveAll(filepath.Join(d.cfg.LocalDir, f.Path)); err != nil {
+				return fmt.Errorf("could not remove '%s': %w", f.Path, err)
+			}
+		}
+		if err = q.DeleteFilesUnder(ctx, f.Path); err != nil {
+			return fmt.Errorf("could not delete '%s' from database: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// downloadIncluded downloads all selected files that are not synced yet. Downloads failing with transient errors are
+// retried, files that still cannot be downloaded are recorded as failed downloads and retried by the daemon.
+func (d *daemon) downloadIncluded(ctx context.Context) error {
+	q := d.db.Queries()
+	drives, err := q.GetSharedDrives(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get shared drives: %w", err)
+	}
+	sharedDrives := make([]config.SharedDrive, len(drives))
+	for i, sd := range drives {
+		sharedDrives[i] = config.SharedDrive{ID: sd.DriveID, Name: sd.Name, LocalDir: sd.LocalDir}
+	}
+
+	syncCtx, err := collectFiles(ctx, d.cfg, d.drv, d.cfg.LocalDir, sharedDrives)
+	if err != nil {
+		return err
+	}
+	if err = createDirs(d.cfg.LocalDir, syncCtx); err != nil {
+		return fmt.Errorf("could not create directories: %w", err)
+	}
+	if err = persistDirs(ctx, q, syncCtx); err != nil {
+		return fmt.Errorf("could not persist directories: %w", err)
+	}
+
+	for _, f := range syncCtx.fileMap {
+		if f.MimeType == FolderMimeType || isUnexported(d.cfg, f.MimeType) {
+			continue
+		}
+		known, err := q.GetFilesByDriveID(ctx, syncCtx.source(f.Id))
+		if err != nil {
+			return fmt.Errorf("could not look up '%s': %w", f.Name, err)
+		}
+		shortcutID := syncCtx.followedBy[f.Id]
+		if slices.ContainsFunc(known, func(k sqlc.File) bool { return k.ShortcutID == shortcutID }) {
+			continue
+		}
+		var pf *parkFile
+		err = withRetry(
+			ctx, f.Name, func() (err error) {
+				pf, err = downloadFile(ctx, d.drv, d.cfg, d.cfg.LocalDir, f, syncCtx)
+				return err
+			},
+		)
+		if err != nil {
+			relativePath, _ := downloadPath(d.cfg, f, syncCtx)
+			logging.Errorf("Could not download %s: %s", relativePath, err)
+			err = q.UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{
+				DriveID:     f.Id,
+				Path:        relativePath,
+				Error:       err.Error(),
+				LastAttempt: time.Now().Unix(),
+			})
+			if err != nil {
+				return fmt.Errorf("could not persist failed download of '%s': %w", relativePath, err)
+			}
+			continue
+		}
+		logging.Infof("Downloaded %s, it is now selected for sync", pf.Path)
+		err = d.db.WithTransaction(
+			ctx, func(q *sqlc.Queries) error {
+				if err := trackFile(ctx, q, *pf); err != nil {
+					return err
+				}
+				// The file may have failed in a previous run
+				return q.DeleteFailedDownload(ctx, pf.FileId)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("could not persist file: %w", err)
+		}
+	}
+	return linkShortcuts(ctx, q, d.cfg, d.cfg.LocalDir, syncCtx)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/googleapi"
+)
+
+func TestApplySelectionDownloadsIncludedFolders(t *testing.T) {
+	tests := []struct {
+		name string
+		// err is returned by the first download of the newly included file
+		err    error
+		failed bool
+	}{
+		{name: "downloaded"},
+		{name: "transient error is retried", err: io.ErrUnexpectedEOF},
+		{name: "permanent error is recorded", err: &googleapi.Error{Code: http.StatusForbidden}, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			fake.AddFile(fake.AddFolder(fake.RootID(), "A"), "a.txt", []byte("a"))
+			b := fake.AddFile(fake.AddFolder(fake.RootID(), "B"), "b.txt", []byte("b"))
+			cfg := testConfig(t)
+			cfg.SyncExclude = []string{"B"}
+			d := testDatabase(t, cfg)
+			if _, err := performInitialSync(ctx, d, cfg, fake, cfg.LocalDir, nil); err != nil {
+				t.Fatalf("performInitialSync() = %v", err)
+			}
+
+			cfg.SyncExclude = nil
+			if tt.err != nil {
+				fake.InjectError("Download", tt.err)
+			}
+			if err := ApplySelection(ctx, cfg, fake, false); err != nil {
+				t.Fatalf("ApplySelection() = %v", err)
+			}
+
+			failed, err := d.Queries().GetFailedDownloads(ctx)
+			if err != nil {
+				t.Fatalf("could not get failed downloads: %s", err)
+			}
+			if tt.failed {
+				if len(failed) != 1 || failed[0].DriveID != b || failed[0].Path != "B/b.txt" {
+					t.Errorf("failed downloads = %v, want B/b.txt", failed)
+				}
+				return
+			}
+			if len(failed) != 0 {
+				t.Errorf("failed downloads = %v, want none", failed)
+			}
+			assertContent(t, cfg.LocalDir, "B/b.txt", "b")
+			if _, err = d.Queries().GetFile(ctx, "B/b.txt"); err != nil {
+				t.Errorf("B/b.txt is not tracked: %s", err)
+			}
+		})
+	}
+}
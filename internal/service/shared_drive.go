@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/drive/v3"
+)
+
+// listSharedDrives returns all shared drives the user is a member of.
+func listSharedDrives(ctx context.Context, drv gdrive.Remote) ([]config.SharedDrive, error) {
+	available, err := drv.ListSharedDrives(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list shared drives: %w", err)
+	}
+	drives := make([]config.SharedDrive, len(available))
+	for i, d := range available {
+		drives[i] = config.SharedDrive{ID: d.Id, Name: d.Name}
+	}
+	return drives, nil
+}
+
+// walkSharedDrive collects the content of the shared drive sd into syncCtx as if it was a folder at the
+// subdirectory of sd in the local directory.
+func walkSharedDrive(
+	ctx context.Context,
+	drv gdrive.Remote,
+	sd config.SharedDrive,
+	intoDir string,
+	syncCtx *syncContext,
+) error {
+	if collidesWithMyDrive(sd.LocalDir, syncCtx) {
+		return fmt.Errorf("subdirectory '%s' of shared drive '%s' collides with My Drive", sd.LocalDir, sd.Name)
+	}
+
+	syncCtx.driveID = sd.ID
+	defer func() { syncCtx.driveID = "" }()
+	if err := walkFolder(ctx, drv, sd.ID, filepath.Join(intoDir, sd.LocalDir), syncCtx); err != nil {
+		return fmt.Errorf("error walking shared drive '%s': %w", sd.Name, err)
+	}
+	// The root folder of a shared drive has the ID of the drive
+	syncCtx.fileMap[sd.ID] = &drive.File{Id: sd.ID, Name: sd.LocalDir, MimeType: FolderMimeType}
+	return nil
+}
+
+// isSharedDriveParent reports whether the local directory at relativePath contains the directory of a synced shared
+// drive without being part of any drive itself.
+func (d *daemon) isSharedDriveParent(relativePath string) bool {
+	for _, localDir := range d.sharedDrives {
+		if localDir != relativePath && isBelow(localDir, relativePath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+// sharedWithMeID is the pseudo Drive ID of the directory files shared with the user are synced to. Files cannot be
+// created in it, only below the folders shared with the user.
+const sharedWithMeID = "sharedWithMe"
+
+// walkSharedWithMe collects the files and folders shared with the user that are included by the config into syncCtx
+// as children of config.SharedWithMeDir.
+func walkSharedWithMe(
+	ctx context.Context,
+	drv gdrive.Remote,
+	cfg config.Config,
+	intoDir string,
+	syncCtx *syncContext,
+) error {
+	if collidesWithMyDrive(config.SharedWithMeDir, syncCtx) {
+		return fmt.Errorf("directory '%s' collides with My Drive", config.SharedWithMeDir)
+	}
+
+	path := filepath.Join(intoDir, config.SharedWithMeDir)
+	files, err := drv.ListSharedWithMe(ctx, walkFields+", owners(emailAddress)")
+	if err != nil {
+		return fmt.Errorf("could not list files shared with you: %w", err)
+	}
+	for _, f := range files {
+		if _, ok := syncCtx.fileMap[f.Id]; ok {
+			// Already synced as part of My Drive
+			continue
+		}
+		if !cfg.IncludesSharedWithMe(f.Name, ownerEmails(f)) {
+			logging.Debugf("Skipping %s, it is not included in the shared files to sync", f.Name)
+			continue
+		}
+		if err := handleFile(ctx, drv, f, sharedWithMeID, path, syncCtx); err != nil {
+			logging.Errorf("error handling shared file %s: %s", f.Name, err)
+		}
+	}
+	syncCtx.fileMap[sharedWithMeID] = &drive.File{
+		Id:       sharedWithMeID,
+		Name:     config.SharedWithMeDir,
+		MimeType: FolderMimeType,
+	}
+	return nil
+}
+
+// sharedWithMePath returns the local path of f if it was shared with the user and is included by the config.
+func (d *daemon) sharedWithMePath(f *drive.File) (string, bool) {
+	if f.SharedWithMeTime == "" || !d.cfg.IncludesSharedWithMe(f.Name, ownerEmails(f)) {
+		return "", false
+	}
+	return filepath.Join(config.SharedWithMeDir, f.Name), true
+}
+
+func ownerEmails(f *drive.File) []string {
+	emails := make([]string, len(f.Owners))
+	for i, o := range f.Owners {
+		emails[i] = o.EmailAddress
+	}
+	return emails
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+// shortcutLink is a shortcut found during the initial sync that is materialized as symlink.
+type shortcutLink struct {
+	file *drive.File
+	// path is the absolute local path of the shortcut
+	path string
+}
+
+// linkShortcuts creates symlinks for all shortcuts collected while walking whose target was synced as well.
+func linkShortcuts(
+	ctx context.Context,
+	q *sqlc.Queries,
+	cfg config.Config,
+	intoDir string,
+	syncCtx *syncContext,
+) error {
+	for _, s := range syncCtx.shortcuts {
+		target := syncCtx.fileMap[s.file.ShortcutDetails.TargetId]
+		if target == nil {
+			logging.Infof("Skipping shortcut %s, its target is not synced", s.file.Name)
+			continue
+		}
+		targetPath := localPath(target, syncCtx)
+		if ext := exportExtension(cfg, target.MimeType); ext != "" {
+			targetPath += "." + ext
+		}
+		relativePath, err := filepath.Rel(intoDir, s.path)
+		if err != nil {
+			return fmt.Errorf("could not determine relative path of shortcut '%s': %w", s.file.Name, err)
+		}
+		if err = createShortcutLink(ctx, q, intoDir, relativePath, targetPath, s.file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isFollowedCopy reports whether driveID is the key of a failed download of a file synced through a followed shortcut,
+// which is made up of the ID of the shortcut and the ID of the file.
+func isFollowedCopy(driveID string) bool {
+	return strings.Contains(driveID, "/")
+}
+
+// splitCopies separates the files synced at the location of their Drive file from the copies synced through followed
+// shortcuts.
+func splitCopies(files []sqlc.File) (own, copies []sqlc.File) {
+	for _, f := range files {
+		if f.ShortcutID == "" {
+			own = append(own, f)
+		} else {
+			copies = append(copies, f)
+		}
+	}
+	return own, copies
+}
+
+// isFollowedRoot reports whether the copy f is the local copy of a followed shortcut itself rather than of a file below
+// the target of the shortcut.
+func isFollowedRoot(ctx context.Context, q *sqlc.Queries, f sqlc.File) (bool, error) {
+	if f.ShortcutID == "" {
+		return false, nil
+	}
+	parent, err := q.GetFile(ctx, filepath.Dir(f.Path))
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not look up parent of '%s': %w", f.Path, err)
+	}
+	return parent.ShortcutID != f.ShortcutID, nil
+}
+
+// followedRoots returns the local copies of the followed shortcut with the given ID.
+func followedRoots(ctx context.Context, q *sqlc.Queries, shortcutID string) ([]sqlc.File, error) {
+	files, err := q.GetFilesByShortcutID(ctx, shortcutID)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up copies of shortcut '%s': %w", shortcutID, err)
+	}
+	var roots []sqlc.File
+	for _, f := range files {
+		root, err := isFollowedRoot(ctx, q, f)
+		if err != nil {
+			return nil, err
+		}
+		if root {
+			roots = append(roots, f)
+		}
+	}
+	return roots, nil
+}
+
+// inheritShortcutID records the file at relativePath as synced through the followed shortcut its parent was synced
+// through, if any, e.g. after it was created locally below the copy of a followed folder.
+func inheritShortcutID(ctx context.Context, q *sqlc.Queries, relativePath string) error {
+	parent, err := q.GetFile(ctx, filepath.Dir(relativePath))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not look up parent of '%s': %w", relativePath, err)
+	}
+	return q.SetShortcutID(ctx, sqlc.SetShortcutIDParams{ShortcutID: parent.ShortcutID, Path: relativePath})
+}
+
+// applyToCopies syncs the change c to the copies of its file that were synced through followed shortcuts. Copies are
+// moved along with their file as long as it stays below the target of their shortcut and removed once it leaves it,
+// files added to the target are added to its copies. Downloads that fail are recorded to be retried.
+func (d *daemon) applyToCopies(ctx context.Context, q *sqlc.Queries, c *drive.Change, copies []sqlc.File) error {
+	if c.Removed || c.File == nil || c.File.Trashed {
+		return d.removeLocal(ctx, q, copies)
+	}
+	f := c.File
+	var parents []sqlc.File
+	if len(f.Parents) > 0 {
+		tracked, err := q.GetFilesByDriveID(ctx, f.Parents[0])
+		if err != nil {
+			return fmt.Errorf("could not look up parent: %w", err)
+		}
+		_, parents = splitCopies(tracked)
+	}
+	name := f.Name
+	if exportFormat := exportExtension(d.cfg, f.MimeType); exportFormat != "" {
+		name += "." + exportFormat
+	}
+
+	for _, cp := range copies {
+		relativePath := cp.Path
+		root, err := isFollowedRoot(ctx, q, cp)
+		if err != nil {
+			return err
+		}
+		if !root {
+			i := slices.IndexFunc(parents, func(p sqlc.File) bool { return p.ShortcutID == cp.ShortcutID })
+			if i < 0 {
+				// The file was moved out of the target of the shortcut
+				if err = d.removeLocal(ctx, q, []sqlc.File{cp}); err != nil {
+					return err
+				}
+				continue
+			}
+			relativePath = filepath.Join(parents[i].Path, name)
+		}
+		if relativePath != cp.Path {
+			if err = d.moveLocal(ctx, q, cp.Path, relativePath); err != nil {
+				return err
+			}
+			cp.Path = relativePath
+		}
+		if err = d.applyToCopy(ctx, q, relativePath, cp.ShortcutID, []sqlc.File{cp}, f); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range parents {
+		if slices.ContainsFunc(copies, func(cp sqlc.File) bool { return cp.ShortcutID == p.ShortcutID }) {
+			continue
+		}
+		// The file was added to the target of the shortcut
+		if err := d.applyToCopy(ctx, q, filepath.Join(p.Path, name), p.ShortcutID, nil, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyToCopy syncs the remotely changed f to its copy at relativePath, synced through the followed shortcut with the
+// ID shortcutID.
+func (d *daemon) applyToCopy(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath, shortcutID string,
+	existing []sqlc.File,
+	f *drive.File,
+) error {
+	if f.MimeType == ShortcutMimeType {
+		logging.Debugf("Skipping shortcut %s, shortcuts are only followed during the initial sync", relativePath)
+		return nil
+	}
+	err := d.applyContent(ctx, q, relativePath, existing, f)
+	if err != nil {
+		return d.recordFailedDownload(ctx, q, shortcutID+"/"+f.Id, err)
+	}
+	return q.SetShortcutID(ctx, sqlc.SetShortcutIDParams{ShortcutID: shortcutID, Path: relativePath})
+}
+
+// retryCopy downloads the file of fd, which failed to download through the followed shortcut it was synced through,
+// to the path of its copy.
+func (d *daemon) retryCopy(ctx context.Context, q *sqlc.Queries, fd sqlc.FailedDownload) error {
+	ids := strings.Split(fd.DriveID, "/")
+	shortcutID := ""
+	if len(ids) > 1 {
+		shortcutID = ids[len(ids)-2]
+	}
+	f, err := d.drv.Get(ctx, ids[len(ids)-1], retryFields)
+	if err == nil && f.MimeType == ShortcutMimeType && f.ShortcutDetails != nil &&
+		f.ShortcutDetails.TargetMimeType != FolderMimeType && d.cfg.ShortcutMode == config.ShortcutFollow {
+		// The content of the target of a file shortcut is synced to the path of the shortcut
+		shortcutID = f.Id
+		f, err = d.drv.Get(ctx, f.ShortcutDetails.TargetId, retryFields)
+	}
+	if isNotFound(err) || err == nil && (f.Trashed || f.MimeType == ShortcutMimeType) ||
+		shortcutID == "" || d.cfg.ShortcutMode != config.ShortcutFollow {
+		logging.Debugf("Not retrying %s, it is no longer synced", fd.Path)
+		return q.DeleteFailedDownload(ctx, fd.DriveID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get file: %w", err)
+	}
+
+	logging.Infof("Retrying download of %s", fd.Path)
+	var existing []sqlc.File
+	known, err := q.GetFile(ctx, fd.Path)
+	switch {
+	case err == nil:
+		existing = []sqlc.File{known}
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("could not look up file: %w", err)
+	}
+	if err = d.applyContent(ctx, q, fd.Path, existing, f); err != nil {
+		return err
+	}
+	err = q.SetShortcutID(ctx, sqlc.SetShortcutIDParams{ShortcutID: shortcutID, Path: fd.Path})
+	if err != nil {
+		return err
+	}
+	return q.DeleteFailedDownload(ctx, fd.DriveID)
+}
+
+// removeFollowed removes the local copies of the followed shortcut with the given ID, e.g. after the shortcut was
+// removed remotely.
+func (d *daemon) removeFollowed(ctx context.Context, q *sqlc.Queries, shortcutID string) error {
+	roots, err := followedRoots(ctx, q, shortcutID)
+	if err != nil {
+		return err
+	}
+	return d.removeLocal(ctx, q, roots)
+}
+
+// applyShortcut materializes the remotely changed shortcut f at relativePath according to the configured mode.
+func (d *daemon) applyShortcut(ctx context.Context, q *sqlc.Queries, relativePath string, f *drive.File) error {
+	if d.cfg.ShortcutMode == config.ShortcutFollow {
+		return d.moveFollowed(ctx, q, relativePath, f)
+	}
+	if d.cfg.ShortcutMode != config.ShortcutSymlink {
+		logging.Debugf("Skipping shortcut %s", relativePath)
+		return nil
+	}
+	if f.ShortcutDetails == nil {
+		return fmt.Errorf("shortcut without details: %s (%s)", f.Name, f.Id)
+	}
+	tracked, err := q.GetFilesByDriveID(ctx, f.ShortcutDetails.TargetId)
+	if err != nil {
+		return fmt.Errorf("could not look up shortcut target: %w", err)
+	}
+	targets, _ := splitCopies(tracked)
+	if len(targets) == 0 {
+		logging.Infof("Skipping shortcut %s, its target is not synced", relativePath)
+		return nil
+	}
+	return createShortcutLink(ctx, q, d.cfg.LocalDir, relativePath, targets[0].Path, f)
+}
+
+// moveFollowed moves the local copies of the followed shortcut f to relativePath, e.g. after it was renamed remotely.
+func (d *daemon) moveFollowed(ctx context.Context, q *sqlc.Queries, relativePath string, f *drive.File) error {
+	roots, err := followedRoots(ctx, q, f.Id)
+	if err != nil {
+		return err
+	}
+	if len(roots) == 0 {
+		logging.Debugf("Skipping shortcut %s, shortcuts are only followed during the initial sync", relativePath)
+		return nil
+	}
+	for _, root := range roots {
+		newPath := relativePath
+		if root.ExportFormat != "" {
+			newPath += "." + root.ExportFormat
+		}
+		if root.Path == newPath {
+			continue
+		}
+		if err = d.moveLocal(ctx, q, root.Path, newPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createShortcutLink creates a relative symlink at relativePath pointing to targetPath, both relative to rootDir,
+// and records it as synced state of the shortcut f.
+func createShortcutLink(
+	ctx context.Context,
+	q *sqlc.Queries,
+	rootDir, relativePath, targetPath string,
+	f *drive.File,
+) error {
+	absoluteLinkPath := filepath.Join(rootDir, relativePath)
+	target, err := filepath.Rel(filepath.Dir(absoluteLinkPath), filepath.Join(rootDir, targetPath))
+	if err != nil {
+		return fmt.Errorf("could not determine target of shortcut '%s': %w", relativePath, err)
+	}
+	if err = os.MkdirAll(filepath.Dir(absoluteLinkPath), 0755); err != nil {
+		return fmt.Errorf("could not create parent directory of '%s': %w", relativePath, err)
+	}
+	if info, err := os.Lstat(absoluteLinkPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err = os.Remove(absoluteLinkPath); err != nil {
+			return fmt.Errorf("could not replace shortcut '%s': %w", relativePath, err)
+		}
+	}
+	logging.Debugf("Linking shortcut %s to %s", relativePath, targetPath)
+	if err = os.Symlink(target, absoluteLinkPath); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("could not create symlink for shortcut '%s': %w", relativePath, err)
+	}
+
+	return q.UpsertFile(ctx, sqlc.UpsertFileParams{
+		Path:         relativePath,
+		DriveID:      f.Id,
+		ContentHash:  []byte{},
+		LastModified: time.Now().Unix(),
+		MimeType:     ShortcutMimeType,
+	})
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"google.golang.org/api/googleapi"
+)
+
+// followedTree adds the folder A with the file x.txt to fake and the shortcuts A-link and x-link.txt to them, which
+// are followed by the initial sync. It returns the IDs of the files by their path.
+func followedTree(fake *gdrive.Fake) map[string]string {
+	folder := fake.AddFolder(fake.RootID(), "A")
+	file := fake.AddFile(folder, "x.txt", []byte("x"))
+	return map[string]string{
+		"A":          folder,
+		"A/x.txt":    file,
+		"A-link":     fake.AddShortcut(fake.RootID(), "A-link", folder),
+		"x-link.txt": fake.AddShortcut(fake.RootID(), "x-link.txt", file),
+	}
+}
+
+func TestFollowedShortcuts(t *testing.T) {
+	synced := map[string]string{"A/x.txt": "x", "A-link/x.txt": "x", "x-link.txt": "x"}
+	tests := []struct {
+		name   string
+		local  localChange
+		remote func(t *testing.T, fake *gdrive.Fake, ids map[string]string)
+		want   map[string]string
+		// wantRemote are the files of My Drive after the change, shortcuts have no content
+		wantRemote map[string]string
+	}{
+		{
+			name: "remote modify",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.SetContent(ids["A/x.txt"], []byte("x2")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       map[string]string{"A/x.txt": "x2", "A-link/x.txt": "x2", "x-link.txt": "x2"},
+			wantRemote: map[string]string{"A/x.txt": "x2", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name: "remote add to target",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				fake.AddFile(ids["A"], "y.txt", []byte("y"))
+			},
+			want: map[string]string{
+				"A/x.txt": "x", "A/y.txt": "y", "A-link/x.txt": "x", "A-link/y.txt": "y", "x-link.txt": "x",
+			},
+			wantRemote: map[string]string{"A/x.txt": "x", "A/y.txt": "y", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name: "remote move out of target",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Move(context.Background(), ids["A/x.txt"], "x.txt", fake.RootID()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       map[string]string{"x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"x.txt": "x", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name: "remote trash",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Trash(context.Background(), ids["A/x.txt"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       map[string]string{},
+			wantRemote: map[string]string{"A-link": "", "x-link.txt": ""},
+		},
+		{
+			name: "remote rename of shortcut",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Move(context.Background(), ids["A-link"], "B-link", fake.RootID()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       map[string]string{"A/x.txt": "x", "B-link/x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "B-link": "", "x-link.txt": ""},
+		},
+		{
+			name: "remote trash of shortcut",
+			remote: func(t *testing.T, fake *gdrive.Fake, ids map[string]string) {
+				if err := fake.Trash(context.Background(), ids["A-link"]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       map[string]string{"A/x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "x-link.txt": ""},
+		},
+		{
+			name:       "local modify of copy",
+			local:      localChange{write: map[string]string{"A-link/x.txt": "local"}},
+			want:       map[string]string{"A/x.txt": "local", "A-link/x.txt": "local", "x-link.txt": "local"},
+			wantRemote: map[string]string{"A/x.txt": "local", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name:       "local modify of file shortcut",
+			local:      localChange{write: map[string]string{"x-link.txt": "local"}},
+			want:       map[string]string{"A/x.txt": "local", "A-link/x.txt": "local", "x-link.txt": "local"},
+			wantRemote: map[string]string{"A/x.txt": "local", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name:  "local create in copy",
+			local: localChange{write: map[string]string{"A-link/B/y.txt": "y"}},
+			want: map[string]string{
+				"A/x.txt": "x", "A/B/y.txt": "y", "A-link/x.txt": "x", "A-link/B/y.txt": "y", "x-link.txt": "x",
+			},
+			wantRemote: map[string]string{"A/x.txt": "x", "A/B/y.txt": "y", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name:       "local rename of copy",
+			local:      localChange{rename: map[string]string{"B-link": "A-link"}, events: true},
+			want:       map[string]string{"A/x.txt": "x", "B-link/x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "B-link": "", "x-link.txt": ""},
+		},
+		{
+			name:       "local delete of copy",
+			local:      localChange{remove: []string{"A-link"}},
+			want:       map[string]string{"A/x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "x-link.txt": ""},
+		},
+		{
+			name:       "local delete of file shortcut",
+			local:      localChange{remove: []string{"x-link.txt"}},
+			want:       map[string]string{"A/x.txt": "x", "A-link/x.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "A-link": ""},
+		},
+		{
+			// The file below the copy is the same file as in the target, like on Drive
+			name:       "local delete below copy",
+			local:      localChange{remove: []string{"A-link/x.txt"}},
+			want:       map[string]string{},
+			wantRemote: map[string]string{"A-link": "", "x-link.txt": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			ids := followedTree(fake)
+			cfg := testConfig(t)
+			dmn := newTestDaemon(t, cfg, fake)
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), synced)
+
+			dmn.syncLocalChanges(ctx, tt.local.apply(t, cfg.LocalDir))
+			if tt.remote != nil {
+				tt.remote(t, fake, ids)
+			}
+			if err := dmn.syncRemoteChanges(ctx); err != nil {
+				t.Fatalf("syncRemoteChanges() = %v", err)
+			}
+
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), tt.want)
+			assertFiles(t, "remote", remoteFiles(t, fake), tt.wantRemote)
+			if conflicts, err := dmn.db.Queries().GetConflicts(ctx); err != nil || len(conflicts) > 0 {
+				t.Errorf("GetConflicts() = %v, %v, want none", conflicts, err)
+			}
+		})
+	}
+}
+
+func TestRetryFailedDownloadOfFollowedCopy(t *testing.T) {
+	ctx := context.Background()
+	fake := gdrive.NewFake()
+	ids := followedTree(fake)
+	cfg := testConfig(t)
+	dmn := newTestDaemon(t, cfg, fake)
+
+	if err := fake.SetContent(ids["A/x.txt"], []byte("x2")); err != nil {
+		t.Fatal(err)
+	}
+	// Copies are synced before the file itself, A-link/x.txt first
+	fake.InjectError("Download", &googleapi.Error{Code: http.StatusForbidden})
+	if err := dmn.syncRemoteChanges(ctx); err != nil {
+		t.Fatalf("syncRemoteChanges() = %v", err)
+	}
+	assertFiles(
+		t, "local", localFiles(t, cfg.LocalDir), map[string]string{"A/x.txt": "x2", "A-link/x.txt": "x", "x-link.txt": "x2"},
+	)
+
+	key := ids["A-link"] + "/" + ids["A/x.txt"]
+	err := dmn.db.Queries().UpsertFailedDownload(ctx, sqlc.UpsertFailedDownloadParams{DriveID: key, Path: "A-link/x.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dmn.retryFailedDownloads(ctx); err != nil {
+		t.Fatalf("retryFailedDownloads() = %v", err)
+	}
+	assertFiles(
+		t, "local", localFiles(t, cfg.LocalDir), map[string]string{"A/x.txt": "x2", "A-link/x.txt": "x2", "x-link.txt": "x2"},
+	)
+	if got := failedDownloadPaths(t, dmn.db); len(got) > 0 {
+		t.Errorf("failed downloads = %v, want none", got)
+	}
+	tracked, err := dmn.db.Queries().GetFile(ctx, "A-link/x.txt")
+	if err != nil || tracked.DriveID != ids["A/x.txt"] || tracked.ShortcutID != ids["A-link"] {
+		t.Errorf("A-link/x.txt is tracked as %+v, %v", tracked, err)
+	}
+}
+
+func TestResumeFollowedCopies(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowDeletions bool
+		want           map[string]string
+		wantRemote     map[string]string
+	}{
+		{
+			name:       "restore",
+			want:       map[string]string{"A/x.txt": "x", "A-link/x.txt": "x", "x-link.txt": "x"},
+			wantRemote: map[string]string{"A/x.txt": "x", "A-link": "", "x-link.txt": ""},
+		},
+		{
+			name:           "trash",
+			allowDeletions: true,
+			want:           map[string]string{"A/x.txt": "x"},
+			wantRemote:     map[string]string{"A/x.txt": "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := gdrive.NewFake()
+			followedTree(fake)
+			cfg := testConfig(t)
+			dmn := newTestDaemon(t, cfg, fake)
+			for _, p := range []string{"A-link", "x-link.txt"} {
+				if err := os.RemoveAll(filepath.Join(cfg.LocalDir, p)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := dmn.db.Queries().SetPaused(ctx, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Resume(ctx, cfg, fake, tt.allowDeletions); err != nil {
+				t.Fatalf("Resume() = %v", err)
+			}
+			assertFiles(t, "local", localFiles(t, cfg.LocalDir), tt.want)
+			assertFiles(t, "remote", remoteFiles(t, fake), tt.wantRemote)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/util"
+)
+
+// PrintStatus writes the state of sync of the profile of dirs and of each of its sync pairs to out: whether it is
+// initialized or paused, the number of tracked files and unresolved conflicts, the uploads in progress and the failed
+// downloads.
+func PrintStatus(ctx context.Context, dirs util.Dirs, out io.Writer) error {
+	if err := printStatus(ctx, dirs.Data, out, "Profile", dirs.Profile); err != nil {
+		return err
+	}
+	cfg, _, err := config.Stored(ctx, dirs)
+	if err != nil {
+		return fmt.Errorf("could not get config: %w", err)
+	}
+	for _, p := range cfg.Pairs() {
+		_, _ = fmt.Fprintln(out)
+		if err = printStatus(ctx, p.Dirs.Data, out, "Sync pair", p.RootFolder+" -> "+p.LocalDir); err != nil {
+			return fmt.Errorf("sync pair %s: %w", p.LocalDir, err)
+		}
+	}
+	return nil
+}
+
+// printStatus writes the state of sync kept in the database in dataDir to out, headed by the name of what is synced.
+func printStatus(ctx context.Context, dataDir string, out io.Writer, kind, name string) error {
+	d, err := db.New(ctx, dataDir)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+	q := d.Queries()
+
+	isInitialized, err := q.IsInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check if initialized: %w", err)
+	}
+	isPaused, err := q.IsPaused(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check if sync is paused: %w", err)
+	}
+	tracked, err := q.CountTrackedFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("could not count tracked files: %w", err)
+	}
+	conflicts, err := q.GetUnresolvedConflicts(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get conflicts: %w", err)
+	}
+	uploads, err := q.GetUploads(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get uploads: %w", err)
+	}
+	failed, err := q.GetFailedDownloads(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get failed downloads: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%s:\t%s\n", kind, name)
+	_, _ = fmt.Fprintf(w, "Initialized:\t%s\n", yesNo(isInitialized))
+	_, _ = fmt.Fprintf(w, "Paused:\t%s\n", yesNo(isPaused))
+	_, _ = fmt.Fprintf(w, "Tracked files:\t%d\n", tracked)
+	_, _ = fmt.Fprintf(w, "Unresolved conflicts:\t%d\n", len(conflicts))
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if len(uploads) > 0 {
+		_, _ = fmt.Fprintln(out, "\nUploads in progress:")
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PATH\tPROGRESS\tSIZE\tSTARTED")
+		for _, u := range uploads {
+			_, _ = fmt.Fprintf(
+				w, "%s\t%d%%\t%d\t%s\n", u.Path, u.Uploaded*100/max(u.Size, 1), u.Size, formatUnix(u.StartedAt),
+			)
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		_, _ = fmt.Fprintln(out, "\nFailed downloads:")
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PATH\tATTEMPTS\tLAST ATTEMPT\tERROR")
+		for _, f := range failed {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", f.Path, f.Attempts, formatUnix(f.LastAttempt), f.Error)
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/torfstack/park/internal/config"
+	"github.com/torfstack/park/internal/db"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+const rootFields = "id, name, mimeType, driveId"
+
+// rootFolder returns the Drive folder synced to the local directory of cfg, which is the folder of its sync pair or
+// the root folder of My Drive.
+func rootFolder(ctx context.Context, drv gdrive.Remote, cfg config.Config) (*drive.File, error) {
+	var root *drive.File
+	var err error
+	if id, ok := strings.CutPrefix(cfg.RootFolder, config.FolderIDPrefix); ok {
+		root, err = drv.Get(ctx, id, rootFields)
+	} else {
+		root, err = drv.Get(ctx, RootFolderId, rootFields)
+		if err == nil && cfg.RootFolder != "" && cfg.RootFolder != "." {
+			root, err = findFolder(ctx, drv, root, cfg.RootFolder)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get root folder: %w", err)
+	}
+	if root.MimeType != FolderMimeType {
+		return nil, fmt.Errorf("'%s' is not a folder", cfg.RootFolder)
+	}
+	if root.DriveId != "" {
+		return nil, fmt.Errorf("folder '%s' is located in a shared drive, sync the shared drive instead", cfg.RootFolder)
+	}
+	return root, nil
+}
+
+// findFolder returns the folder at path relative to the folder parent.
+func findFolder(ctx context.Context, drv gdrive.Remote, parent *drive.File, path string) (*drive.File, error) {
+	for name := range strings.SplitSeq(filepath.ToSlash(path), "/") {
+		children, err := drv.ListChildren(ctx, parent.Id, "", rootFields)
+		if err != nil {
+			return nil, fmt.Errorf("could not list files in '%s': %w", parent.Name, err)
+		}
+		i := slices.IndexFunc(
+			children, func(f *drive.File) bool { return f.Name == name && f.MimeType == FolderMimeType },
+		)
+		if i < 0 {
+			return nil, fmt.Errorf("folder '%s' not found", path)
+		}
+		parent = children[i]
+	}
+	return parent, nil
+}
+
+// initialSyncPairs runs the initial sync of every sync pair of cfg that is not initialized yet and marks cfg as
+// initialized once all of them are. Files that could not be downloaded do not keep a pair from being initialized.
+func initialSyncPairs(ctx context.Context, cfg config.Config, drv gdrive.Remote) error {
+	var errs []error
+	initialized := true
+	for _, p := range cfg.Pairs() {
+		logging.Infof("Syncing %s to %s", p.RootFolder, p.LocalDir)
+		if err := InitialSync(ctx, p, drv); err != nil {
+			errs = append(errs, fmt.Errorf("sync pair %s: %w", p.LocalDir, err))
+		}
+		ok, err := isInitialized(ctx, p.Dirs.Data)
+		if err != nil {
+			return err
+		}
+		initialized = initialized && ok
+	}
+	if !initialized {
+		return errors.Join(errs...)
+	}
+
+	d, err := db.New(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+	if err = d.Queries().SetInitialized(ctx); err != nil {
+		return fmt.Errorf("could not set initialized flag: %w", err)
+	}
+	return errors.Join(errs...)
+}
+
+// runPairs runs a daemon for every sync pair of cfg, each with a watcher of its own. Pairs added since the initial
+// sync are initialized first. The pairs are isolated, so a pair that stops, e.g. because its sync was paused, does
+// not stop the others.
+func runPairs(ctx context.Context, cfg config.Config, drv gdrive.Remote) error {
+	pairs := cfg.Pairs()
+	errs := make([]error, len(pairs))
+	var wg sync.WaitGroup
+	for i, p := range pairs {
+		wg.Go(
+			func() {
+				err := runPair(ctx, p, drv)
+				if err != nil {
+					errs[i] = fmt.Errorf("sync pair %s: %w", p.LocalDir, err)
+				}
+			},
+		)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func runPair(ctx context.Context, cfg config.Config, drv gdrive.Remote) error {
+	initialized, err := isInitialized(ctx, cfg.Dirs.Data)
+	if err != nil {
+		return err
+	}
+	if !initialized {
+		logging.Infof("Syncing %s to %s", cfg.RootFolder, cfg.LocalDir)
+		if err = InitialSync(ctx, cfg, drv); err != nil {
+			return err
+		}
+	}
+	return RunDaemon(ctx, cfg, drv)
+}
+
+func isInitialized(ctx context.Context, dataDir string) (bool, error) {
+	d, err := db.New(ctx, dataDir)
+	if err != nil {
+		return false, fmt.Errorf("could not create database: %w", err)
+	}
+	defer d.Close()
+	initialized, err := d.Queries().IsInitialized(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not check if initialized: %w", err)
+	}
+	return initialized, nil
+}
+
+// resumePairs resumes the sync of every sync pair of cfg that is paused.
+func resumePairs(ctx context.Context, cfg config.Config, drv gdrive.Remote, allowDeletions bool) error {
+	for _, p := range cfg.Pairs() {
+		logging.Infof("Resuming %s", p.LocalDir)
+		if err := Resume(ctx, p, drv, allowDeletions); err != nil {
+			return fmt.Errorf("sync pair %s: %w", p.LocalDir, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/gdrive"
+	"github.com/torfstack/park/internal/logging"
+)
+
+var errSyncPaused = errors.New("sync paused, too many files were removed locally; run `park resume`")
+
+// deletionWindow is the time in which trashed files count towards the deletion limits together, so removing many
+// files is caught even if the local events arrive in several batches.
+const deletionWindow = 10 * time.Minute
+
+// deletion is the trashing of the Drive files of a batch of locally removed paths.
+type deletion struct {
+	at    time.Time
+	files int
+}
+
+// trashRemoved moves the Drive files of the given locally removed paths to the trash. If this and the trashing of
+// removed files within the deletion window would trash more files than the configured limits allow, nothing is
+// trashed and sync is paused instead.
+func (d *daemon) trashRemoved(ctx context.Context, removed []string) error {
+	q := d.db.Queries()
+
+	var toTrash []sqlc.File
+	numFiles := 0
+	for _, p := range removed {
+		files, err := q.GetFilesUnder(ctx, p)
+		if err != nil {
+			return fmt.Errorf("could not look up '%s': %w", p, err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if _, ok := d.sharedDrives[files[0].DriveID]; ok {
+			logging.Errorf("Not trashing the content of shared drive %s, its directory was removed locally", p)
+			continue
+		}
+		if files[0].DriveID == sharedWithMeID {
+			logging.Errorf("Not trashing the files shared with you, %s was removed locally", p)
+			continue
+		}
+		// The first file is the removed path itself, the rest are its descendants
+		toTrash = append(toTrash, files[0])
+		for _, f := range files {
+			if f.MimeType != FolderMimeType {
+				numFiles++
+			}
+		}
+	}
+	if len(toTrash) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	d.deletions = slices.DeleteFunc(d.deletions, func(del deletion) bool { return now.Sub(del.at) > deletionWindow })
+	recent := 0
+	for _, del := range d.deletions {
+		recent += del.files
+	}
+	total, err := q.CountTrackedFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("could not count tracked files: %w", err)
+	}
+	// The files trashed recently were tracked when the window started
+	numDeletions, numTracked := recent+numFiles, int(total)+recent
+	if exceedsDeletionLimit(d.cfg.MaxDeletions, d.cfg.MaxDeletionPercent, numDeletions, numTracked) {
+		logging.Errorf(
+			"!!! Refusing to trash %d of %d tracked files within %s (limits: %d files, %d%%). "+
+				"Sync is paused until you restore the files or confirm the deletion with `park resume --allow-deletions`.",
+			numDeletions, numTracked, deletionWindow, d.cfg.MaxDeletions, d.cfg.MaxDeletionPercent,
+		)
+		return d.pause(ctx)
+	}
+	d.deletions = append(d.deletions, deletion{at: now, files: numFiles})
+
+	for _, f := range toTrash {
+		logging.Infof("Trashing %s, it was removed locally", f.Path)
+		if err = trashFile(ctx, d.drv, q, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pause persists that sync is paused and stops the daemon.
+func (d *daemon) pause(ctx context.Context) error {
+	if err := d.db.Queries().SetPaused(ctx, true); err != nil {
+		return fmt.Errorf("could not pause sync: %w", err)
+	}
+	d.stop(errSyncPaused)
+	return nil
+}
+
+// trashFile moves f to the Drive trash and removes it and its descendants from the database. If f is the copy of a
+// followed shortcut, the shortcut is trashed rather than its target. Files below the copy of a followed folder are
+// trashed like on Drive, where they are the same files as in the target.
+func trashFile(ctx context.Context, drv gdrive.Remote, q *sqlc.Queries, f sqlc.File) error {
+	root, err := isFollowedRoot(ctx, q, f)
+	if err != nil {
+		return err
+	}
+	driveID := f.DriveID
+	if root {
+		driveID = f.ShortcutID
+	}
+	err = drv.Trash(ctx, driveID)
+	switch {
+	case isForbidden(err):
+		// E.g. files shared with the user can only be trashed by their owner, the local removal stays local
+		logging.Infof("Not trashing %s on Drive, you are not allowed to", f.Path)
+	case err != nil && !isNotFound(err):
+		return fmt.Errorf("could not trash '%s': %w", f.Path, err)
+	}
+	if err = q.DeleteFilesUnder(ctx, f.Path); err != nil {
+		return fmt.Errorf("could not delete '%s' from database: %w", f.Path, err)
+	}
+	return nil
+}
+
+func exceedsDeletionLimit(maxDeletions, maxPercent, numDeletions, numTracked int) bool {
+	if maxDeletions > 0 && numDeletions > maxDeletions {
+		return true
+	}
+	return maxPercent > 0 && numTracked > 0 && numDeletions*100 > numTracked*maxPercent
+}
+
+// isBelow reports whether path equals dir or is located inside of it.
+func isBelow(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func isBelowAny(path string, dirs []string) bool {
+	return slices.ContainsFunc(dirs, func(dir string) bool { return isBelow(path, dir) })
+}
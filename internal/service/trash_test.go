@@ -0,0 +1,39 @@
+package service
+
+import "testing"
+
+func TestExceedsDeletionLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxDeletions int
+		maxPercent   int
+		numDeletions int
+		numTracked   int
+		want         bool
+	}{
+		{"below both limits", 10, 50, 5, 100, false},
+		{"at the deletion limit", 10, 0, 10, 100, false},
+		{"above the deletion limit", 10, 0, 11, 100, true},
+		{"deletion limit disabled", 0, 0, 1000, 1000, false},
+		{"deletion limit disabled, percent exceeded", 0, 50, 51, 100, true},
+		{"at the percent limit", 0, 50, 2, 4, false},
+		{"above the percent limit", 0, 50, 3, 4, true},
+		{"fraction above the percent limit", 0, 33, 1, 3, true},
+		{"fraction below the percent limit", 0, 34, 1, 3, false},
+		{"percent limit disabled", 100, 0, 4, 4, false},
+		{"empty database", 10, 50, 0, 0, false},
+		{"deletions without tracked files", 10, 50, 5, 0, false},
+		{"deletions without tracked files above the deletion limit", 10, 50, 11, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exceedsDeletionLimit(tt.maxDeletions, tt.maxPercent, tt.numDeletions, tt.numTracked)
+			if got != tt.want {
+				t.Errorf(
+					"exceedsDeletionLimit(%d, %d, %d, %d) = %t, want %t",
+					tt.maxDeletions, tt.maxPercent, tt.numDeletions, tt.numTracked, got, tt.want,
+				)
+			}
+		})
+	}
+}
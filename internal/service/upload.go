@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/torfstack/park/internal/db/sqlc"
+	"github.com/torfstack/park/internal/logging"
+	"google.golang.org/api/drive/v3"
+)
+
+const (
+	// resumableThreshold is the size from which files are uploaded in chunks using a resumable upload session
+	resumableThreshold = 16 << 20
+	// uploadChunkSize is the size of the chunks of a resumable upload, Drive requires a multiple of 256 KiB
+	uploadChunkSize = 8 << 20
+)
+
+// uploadResumable uploads the local file at relativePath with the given hash as new content of the Drive file with
+// the given ID, or as the new file described by metadata if driveID is empty. The upload session is persisted, so an
+// upload of the same content that was interrupted, e.g. by a restart, continues where it stopped.
+func (d *daemon) uploadResumable(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath, driveID string,
+	metadata *drive.File,
+	hash []byte,
+) (*drive.File, error) {
+	in, err := os.Open(filepath.Join(d.cfg.LocalDir, relativePath))
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not stat file: %w", err)
+	}
+	size := info.Size()
+
+	sessionURI, offset, uploaded, err := d.resumeUpload(ctx, q, relativePath, driveID, size, hash)
+	if err != nil {
+		return nil, err
+	}
+	if sessionURI == "" {
+		if sessionURI, err = d.drv.StartUpload(ctx, driveID, metadata, size, uploadFields); err != nil {
+			return nil, fmt.Errorf("could not start upload: %w", err)
+		}
+		err = q.UpsertUpload(ctx, sqlc.UpsertUploadParams{
+			Path:        relativePath,
+			DriveID:     driveID,
+			SessionUri:  sessionURI,
+			Size:        size,
+			ContentHash: hash,
+			StartedAt:   time.Now().Unix(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not persist upload: %w", err)
+		}
+	}
+
+	for uploaded == nil {
+		chunk := make([]byte, min(uploadChunkSize, size-offset))
+		_, err = io.ReadFull(throttleUpload(d.cfg, io.NewSectionReader(in, offset, int64(len(chunk)))), chunk)
+		if err != nil {
+			return nil, fmt.Errorf("could not read file: %w", err)
+		}
+		uploaded, offset, err = d.drv.UploadChunk(ctx, sessionURI, chunk, offset, size)
+		if err != nil {
+			return nil, fmt.Errorf("could not upload chunk: %w", err)
+		}
+		if uploaded != nil {
+			break
+		}
+		err = q.UpdateUploadProgress(ctx, sqlc.UpdateUploadProgressParams{Uploaded: offset, Path: relativePath})
+		if err != nil {
+			return nil, fmt.Errorf("could not persist upload progress: %w", err)
+		}
+		logging.Infof("Uploaded %d%% of %s", offset*100/size, relativePath)
+	}
+
+	if err = q.DeleteUpload(ctx, relativePath); err != nil {
+		return nil, fmt.Errorf("could not delete finished upload: %w", err)
+	}
+	return uploaded, nil
+}
+
+// resumeUpload looks up the persisted upload session of relativePath. It returns the URI of the session and the
+// number of bytes Drive received so far, or the uploaded file if the upload is already complete. The URI is empty if
+// there is no session for the given content that can be continued.
+func (d *daemon) resumeUpload(
+	ctx context.Context,
+	q *sqlc.Queries,
+	relativePath, driveID string,
+	size int64,
+	hash []byte,
+) (string, int64, *drive.File, error) {
+	upload, err := q.GetUpload(ctx, relativePath)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", 0, nil, nil
+	case err != nil:
+		return "", 0, nil, fmt.Errorf("could not get upload: %w", err)
+	case upload.DriveID != driveID || upload.Size != size || !bytes.Equal(upload.ContentHash, hash):
+		logging.Debugf("Discarding upload of %s, the file changed since it was started", relativePath)
+		return "", 0, nil, nil
+	}
+
+	uploaded, offset, err := d.drv.UploadStatus(ctx, upload.SessionUri, size)
+	if isNotFound(err) || isGone(err) {
+		logging.Debugf("Restarting upload of %s, its session expired", relativePath)
+		return "", 0, nil, nil
+	}
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("could not query upload: %w", err)
+	}
+	if uploaded == nil {
+		logging.Infof("Resuming upload of %s at %d%%", relativePath, offset*100/size)
+	}
+	return upload.SessionUri, offset, uploaded, nil
+}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Bandwidth limits the combined rate at which its readers read, e.g. all downloads or all uploads.
+type Bandwidth struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewBandwidth() *Bandwidth {
+	return &Bandwidth{last: time.Now()}
+}
+
+// Reader returns a reader that reads from r no faster than rate bytes per second together with all other readers
+// of b. The rate is called on every read, so it may change while r is read; a rate of 0 or less is unlimited.
+func (b *Bandwidth) Reader(r io.Reader, rate func() int64) io.Reader {
+	return &throttledReader{r: r, b: b, rate: rate}
+}
+
+// take consumes n bytes of the budget and blocks until the budget is no longer overdrawn.
+func (b *Bandwidth) take(n int, rate int64) {
+	b.mu.Lock()
+	now := time.Now()
+	// Up to a second of unused bandwidth is saved up for bursts
+	b.tokens = min(float64(rate), b.tokens+now.Sub(b.last).Seconds()*float64(rate))
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / float64(rate) * float64(time.Second)))
+	}
+}
+
+type throttledReader struct {
+	r    io.Reader
+	b    *Bandwidth
+	rate func() int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	rate := t.rate()
+	if rate <= 0 {
+		return t.r.Read(p)
+	}
+	// Small reads keep the rate smooth instead of alternating between bursts and long pauses
+	if chunk := max(rate/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.b.take(n, rate)
+	}
+	return n, err
+}
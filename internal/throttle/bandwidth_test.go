@@ -0,0 +1,45 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBandwidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int64
+		readers int
+		// size is the number of bytes read by each reader
+		size    int
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		{name: "unlimited", rate: 0, readers: 1, size: 1 << 20, maxTime: 100 * time.Millisecond},
+		{name: "limited", rate: 20_000, readers: 1, size: 10_000, minTime: 400 * time.Millisecond, maxTime: 2 * time.Second},
+		{name: "shared", rate: 20_000, readers: 2, size: 5_000, minTime: 400 * time.Millisecond, maxTime: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBandwidth()
+			start := time.Now()
+			var wg sync.WaitGroup
+			for range tt.readers {
+				wg.Go(func() {
+					r := b.Reader(bytes.NewReader(make([]byte, tt.size)), func() int64 { return tt.rate })
+					n, err := io.Copy(io.Discard, r)
+					if err != nil || n != int64(tt.size) {
+						t.Errorf("read %d bytes, %v, want %d", n, err, tt.size)
+					}
+				})
+			}
+			wg.Wait()
+			if elapsed := time.Since(start); elapsed < tt.minTime || elapsed > tt.maxTime {
+				t.Errorf("reading took %s, want between %s and %s", elapsed, tt.minTime, tt.maxTime)
+			}
+		})
+	}
+}
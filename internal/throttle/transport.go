@@ -0,0 +1,319 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/torfstack/park/internal/logging"
+)
+
+const (
+	// requestsPerSecond and burst bound the rate of requests, matching the default per-user quota of the Drive API
+	requestsPerSecond = 10
+	burst             = 20
+	// maxConcurrent is the number of requests in flight when the API does not push back
+	maxConcurrent = 8
+
+	maxRequestAttempts    = 6
+	initialRequestBackoff = time.Second
+	maxRequestBackoff     = time.Minute
+	// maxErrorBody is the number of bytes of an error response read to find out why a request was rejected
+	maxErrorBody = 64 << 10
+)
+
+// Transport limits the rate and the concurrency of requests to the Drive API and retries requests that were rejected
+// because of rate limits or server errors. The concurrency is halved whenever the API pushes back and slowly grows
+// again while requests succeed. A request counts towards the concurrency until its response headers arrive, reading
+// the body of a long download does not hold back other requests.
+type Transport struct {
+	base    http.RoundTripper
+	limiter *limiter
+	slots   *adaptiveLimit
+	// backoff is the delay before the first retry of a request, unless the API asks for another one
+	backoff time.Duration
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:    base,
+		limiter: newLimiter(requestsPerSecond, burst),
+		slots:   newAdaptiveLimit(maxConcurrent),
+		backoff: initialRequestBackoff,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		if err := t.slots.acquire(ctx); err != nil {
+			return nil, err
+		}
+
+		res, err := t.base.RoundTrip(req)
+		t.slots.release()
+		retry, pushBack := shouldRetry(req, res, err)
+		if pushBack {
+			t.slots.backOff()
+		}
+		if !retry || attempt == maxRequestAttempts || !canResend(req) {
+			if err != nil {
+				return nil, err
+			}
+			if res.StatusCode < http.StatusBadRequest {
+				t.slots.succeeded()
+			}
+			return res, nil
+		}
+
+		delay := retryAfter(res)
+		if delay > 0 {
+			// Retry-After applies to all requests, not only to this one
+			t.limiter.pause(delay)
+		} else {
+			delay = backoff + rand.N(backoff/2)
+			backoff = min(2*backoff, maxRequestBackoff)
+		}
+		if res != nil {
+			logging.Debugf("Drive API responded with %s, retrying in %s", res.Status, delay.Round(time.Millisecond))
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		} else {
+			logging.Debugf("Drive API request failed, retrying in %s: %s", delay.Round(time.Millisecond), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry reports whether the request should be retried and whether the API asked to slow down.
+func shouldRetry(req *http.Request, res *http.Response, err error) (bool, bool) {
+	if err != nil {
+		// Requests that fail without a response may have been processed, only those without side effects are retried
+		idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+		return idempotent && (errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)), false
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true, true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true, false
+	case http.StatusForbidden:
+		// Permission errors are 403s as well, but only rate limit errors go away when retried
+		limited := isRateLimited(res)
+		return limited, limited
+	}
+	return false, false
+}
+
+// isRateLimited reports whether the error response res was caused by a rate limit. The body of res is restored, so
+// it can still be read by the caller.
+func isRateLimited(res *http.Response) bool {
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+
+	var body struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(b, &body) != nil {
+		return false
+	}
+	for _, e := range body.Error.Errors {
+		switch e.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "sharingRateLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by the Retry-After header of res, 0 if there is none.
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	header := res.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+func canResend(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req whose body can be sent again.
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+// limiter is a token bucket limiting the rate of requests.
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu         sync.Mutex
+	tokens     float64
+	last       time.Time
+	pauseUntil time.Time
+}
+
+func newLimiter(rate, burst float64) *limiter {
+	return &limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until a request may be sent.
+func (l *limiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		var delay time.Duration
+		switch {
+		case now.Before(l.pauseUntil):
+			delay = l.pauseUntil.Sub(now)
+		case l.tokens >= 1:
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		default:
+			delay = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// pause holds back all requests for d.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pauseUntil) {
+		l.pauseUntil = until
+	}
+}
+
+// adaptiveLimit bounds the number of requests in flight. The bound is halved when the API pushes back and grows by
+// one after as many successful requests as the current bound, up to its maximum.
+type adaptiveLimit struct {
+	max int
+
+	mu        sync.Mutex
+	limit     int
+	inFlight  int
+	successes int
+	// released is closed and replaced whenever a slot may have become available
+	released chan struct{}
+}
+
+func newAdaptiveLimit(maximum int) *adaptiveLimit {
+	return &adaptiveLimit{max: maximum, limit: maximum, released: make(chan struct{})}
+}
+
+func (l *adaptiveLimit) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (l *adaptiveLimit) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.notify()
+}
+
+func (l *adaptiveLimit) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit == l.max {
+		return
+	}
+	l.successes++
+	if l.successes >= l.limit {
+		l.limit++
+		l.successes = 0
+		logging.Debugf("Raising concurrent Drive API requests to %d", l.limit)
+		l.notify()
+	}
+}
+
+func (l *adaptiveLimit) backOff() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successes = 0
+	if l.limit > 1 {
+		l.limit /= 2
+		logging.Debugf("Drive API pushed back, lowering concurrent requests to %d", l.limit)
+	}
+}
+
+func (l *adaptiveLimit) notify() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
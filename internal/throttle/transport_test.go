@@ -0,0 +1,333 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// response is a response of a test server.
+type response struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+const (
+	rateLimitBody  = `{"error": {"errors": [{"reason": "userRateLimitExceeded"}]}}`
+	permissionBody = `{"error": {"errors": [{"reason": "insufficientFilePermissions"}]}}`
+)
+
+// newTestServer returns a server answering consecutive requests with responses, further requests are answered with
+// 200. It records the bodies of the requests it received.
+func newTestServer(t *testing.T, responses []response) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		res := response{status: http.StatusOK}
+		if len(bodies) < len(responses) {
+			res = responses[len(bodies)]
+		}
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		if res.retryAfter != "" {
+			w.Header().Set("Retry-After", res.retryAfter)
+		}
+		w.WriteHeader(res.status)
+		_, _ = io.WriteString(w, res.body)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+}
+
+// newTestTransport returns a Transport that retries without waiting for long.
+func newTestTransport() *Transport {
+	tr := NewTransport(nil)
+	tr.backoff = time.Millisecond
+	return tr
+}
+
+func TestTransportRetries(t *testing.T) {
+	repeat := func(res response, n int) []response {
+		responses := make([]response, n)
+		for i := range responses {
+			responses[i] = res
+		}
+		return responses
+	}
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		responses []response
+		// noGetBody sends the body of the request without a way to rewind it
+		noGetBody  bool
+		wantStatus int
+		wantBody   string
+		// wantRequests is the number of requests the server received
+		wantRequests int
+		// wantLimit is the concurrency limit after the request
+		wantLimit int
+		minTime   time.Duration
+	}{
+		{name: "success", wantStatus: 200, wantRequests: 1, wantLimit: maxConcurrent},
+		{
+			name:         "429 is retried",
+			responses:    []response{{status: 429}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+		},
+		{
+			name:         "503 is retried",
+			responses:    []response{{status: 503}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+		},
+		{
+			name:         "429 with Retry-After waits",
+			responses:    []response{{status: 429, retryAfter: "1"}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+			minTime:      time.Second,
+		},
+		{
+			name:         "503 with Retry-After date in the past",
+			responses:    []response{{status: 503, retryAfter: "Mon, 02 Jan 2006 15:04:05 GMT"}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+		},
+		{
+			name:         "5xx is retried without pushing back",
+			responses:    []response{{status: 500}, {status: 502}, {status: 504}},
+			wantStatus:   200,
+			wantRequests: 4,
+			wantLimit:    maxConcurrent,
+		},
+		{
+			name:         "403 rate limit is retried",
+			responses:    []response{{status: 403, body: rateLimitBody}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+		},
+		{
+			name:         "plain 403 is not retried",
+			responses:    []response{{status: 403, body: permissionBody}},
+			wantStatus:   403,
+			wantBody:     permissionBody,
+			wantRequests: 1,
+			wantLimit:    maxConcurrent,
+		},
+		{
+			name:         "404 is not retried",
+			responses:    []response{{status: 404}},
+			wantStatus:   404,
+			wantRequests: 1,
+			wantLimit:    maxConcurrent,
+		},
+		{
+			name:         "gives up after max attempts",
+			responses:    repeat(response{status: 500}, maxRequestAttempts),
+			wantStatus:   500,
+			wantRequests: maxRequestAttempts,
+			wantLimit:    maxConcurrent,
+		},
+		{
+			name:         "request body is replayed",
+			method:       http.MethodPost,
+			body:         "payload",
+			responses:    []response{{status: 503}},
+			wantStatus:   200,
+			wantRequests: 2,
+			wantLimit:    maxConcurrent / 2,
+		},
+		{
+			name:         "request body that cannot be replayed is not retried",
+			method:       http.MethodPost,
+			body:         "payload",
+			noGetBody:    true,
+			responses:    []response{{status: 503}},
+			wantStatus:   503,
+			wantRequests: 1,
+			wantLimit:    maxConcurrent / 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, bodies := newTestServer(t, tt.responses)
+			tr := newTestTransport()
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+				if tt.noGetBody {
+					body = io.NopCloser(body)
+				}
+			}
+			req, err := http.NewRequest(method, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			res, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() = %v", err)
+			}
+			b, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if res.StatusCode != tt.wantStatus || string(b) != tt.wantBody {
+				t.Errorf("RoundTrip() = %d %q, want %d %q", res.StatusCode, b, tt.wantStatus, tt.wantBody)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minTime {
+				t.Errorf("RoundTrip() took %s, want at least %s", elapsed, tt.minTime)
+			}
+			got := bodies()
+			if len(got) != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", len(got), tt.wantRequests)
+			}
+			for i, b := range got {
+				if b != tt.body {
+					t.Errorf("body of request %d = %q, want %q", i+1, b, tt.body)
+				}
+			}
+			if tr.slots.limit != tt.wantLimit || tr.slots.inFlight != 0 {
+				t.Errorf(
+					"limit = %d with %d in flight, want %d with none", tr.slots.limit, tr.slots.inFlight, tt.wantLimit,
+				)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{name: "none"},
+		{name: "seconds", header: "120", min: 2 * time.Minute, max: 2 * time.Minute},
+		{
+			name:   "date",
+			header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			min:    59 * time.Minute,
+			max:    time.Hour,
+		},
+		{name: "date in the past", header: "Mon, 02 Jan 2006 15:04:05 GMT"},
+		{name: "invalid", header: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				res.Header.Set("Retry-After", tt.header)
+			}
+			if got := retryAfter(res); got < tt.min || got > tt.max {
+				t.Errorf("retryAfter(%q) = %s, want between %s and %s", tt.header, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	l := newAdaptiveLimit(4)
+	for _, want := range []int{2, 1, 1} {
+		l.backOff()
+		if l.limit != want {
+			t.Fatalf("limit after backing off = %d, want %d", l.limit, want)
+		}
+	}
+	// The limit grows by one after as many successes as the current limit
+	for _, tt := range []struct{ successes, want int }{{1, 2}, {1, 2}, {1, 3}, {3, 4}, {10, 4}} {
+		for range tt.successes {
+			l.succeeded()
+		}
+		if l.limit != tt.want {
+			t.Fatalf("limit after %d more successes = %d, want %d", tt.successes, l.limit, tt.want)
+		}
+	}
+
+	l.backOff()
+	ctx := context.Background()
+	for range l.limit {
+		if err := l.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(timeout); err == nil {
+		t.Fatal("acquire() succeeded above the limit")
+	}
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(ctx) }()
+	l.release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("acquire() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire() did not return once a slot was released")
+	}
+}
+
+func TestTransportReleasesSlotOnHeaders(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/download" {
+			// The body of a download is still being read
+			<-done
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+	tr := newTestTransport()
+
+	for range maxConcurrent {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/download", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() = %v", err)
+		}
+		defer res.Body.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/metadata", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() while downloads are read = %v", err)
+	}
+	_ = res.Body.Close()
+}
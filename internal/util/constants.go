@@ -1,7 +0,0 @@
-package util
-
-import "path/filepath"
-
-var (
-	ParkConfigDir = filepath.Join(HomeDir(), ".config", "park")
-)
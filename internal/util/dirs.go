@@ -0,0 +1,118 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+const (
+	// HomeEnv is the environment variable that overrides the directory park keeps all its files in
+	HomeEnv = "PARK_HOME"
+	// DatabaseFile is the name of the database in the data directory
+	DatabaseFile = "park.sqlite"
+	// DefaultProfile is the profile used unless another one is selected, its database is kept directly in the data
+	// directory for compatibility with installations that predate profiles
+	DefaultProfile = "default"
+
+	profilesDir  = "profiles"
+	syncPairsDir = "pairs"
+)
+
+var profileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Dirs are the directories park keeps its files in.
+type Dirs struct {
+	// Config contains the OAuth client credentials
+	Config string
+	// Data contains the database with the config and the state of sync
+	Data string
+	// Cache contains temporary files
+	Cache string
+	// Profile is the name of the profile Data and Cache belong to, it is empty for the directories of all profiles
+	Profile string
+}
+
+// ResolveDirs returns dir for all directories unless it is empty. Otherwise, it returns the directory in HomeEnv if it
+// is set and the XDG base directories, e.g. ~/.config/park and ~/.local/share/park, if it is not.
+func ResolveDirs(dir string) Dirs {
+	if dir == "" {
+		dir = os.Getenv(HomeEnv)
+	}
+	if dir != "" {
+		return Dirs{Config: dir, Data: dir, Cache: dir}
+	}
+
+	dirs := Dirs{
+		Config: xdgDir("XDG_CONFIG_HOME", ".config"),
+		Data:   xdgDir("XDG_DATA_HOME", ".local", "share"),
+		Cache:  xdgDir("XDG_CACHE_HOME", ".cache"),
+	}
+	// Before the split into XDG directories, the database was kept in the config directory
+	legacy := filepath.Join(HomeDir(), ".config", "park")
+	if !exists(filepath.Join(dirs.Data, DatabaseFile)) && exists(filepath.Join(legacy, DatabaseFile)) {
+		dirs.Data = legacy
+	}
+	return dirs
+}
+
+// ForProfile returns the directories of the profile with the given name within d, which are the directories of all
+// profiles as returned by ResolveDirs. Profiles share the config directory and with it the OAuth client credentials,
+// but each has its own database and with it its own token, config and state of sync.
+func (d Dirs) ForProfile(name string) (Dirs, error) {
+	if !profileName.MatchString(name) {
+		return Dirs{}, fmt.Errorf("invalid profile name '%s', only letters, digits, '-' and '_' are allowed", name)
+	}
+	if name != DefaultProfile {
+		d.Data = filepath.Join(d.Data, profilesDir, name)
+		d.Cache = filepath.Join(d.Cache, profilesDir, name)
+	}
+	d.Profile = name
+	return d, nil
+}
+
+// ForSyncPair returns the directories of the sync pair with the given key within the directories d of its profile.
+// Sync pairs share the database of their profile, which holds the config and the token, but each keeps the state of
+// its sync in a database of its own in the returned data directory.
+func (d Dirs) ForSyncPair(key string) Dirs {
+	d.Data = filepath.Join(d.Data, syncPairsDir, key)
+	d.Cache = filepath.Join(d.Cache, syncPairsDir, key)
+	return d
+}
+
+// Profiles returns the sorted names of the profiles that have a database within d, which are the directories of all
+// profiles as returned by ResolveDirs.
+func (d Dirs) Profiles() ([]string, error) {
+	var profiles []string
+	if exists(filepath.Join(d.Data, DatabaseFile)) {
+		profiles = append(profiles, DefaultProfile)
+	}
+	entries, err := os.ReadDir(filepath.Join(d.Data, profilesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read profiles: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && profileName.MatchString(e.Name()) &&
+			exists(filepath.Join(d.Data, profilesDir, e.Name(), DatabaseFile)) {
+			profiles = append(profiles, e.Name())
+		}
+	}
+	slices.Sort(profiles)
+	return profiles, nil
+}
+
+// xdgDir returns the park directory in the base directory given by the environment variable env, or in the given
+// fallback relative to the home directory if it is not set or not absolute, as the XDG specification demands.
+func xdgDir(env string, fallback ...string) string {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, "park")
+	}
+	return filepath.Join(append(append([]string{HomeDir()}, fallback...), "park")...)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}